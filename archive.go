@@ -0,0 +1,251 @@
+// archive.go
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Defaults for the archive mover. Archiving is off unless
+// NEWS_ARCHIVE_AFTER is set.
+const (
+	defaultArchiveInterval  = time.Hour
+	defaultArchiveBatchSize = 500
+)
+
+// archiverRunning is set once startArchiver launches the mover.
+// archiverEnabled is the kill switch it checks before every pass; it
+// starts from NEWS_ARCHIVE_ENABLED and can be flipped at runtime through
+// the admin API without a redeploy.
+var (
+	archiverRunning atomic.Bool
+	archiverEnabled atomic.Bool
+)
+
+type ArchiverState struct {
+	Running bool `json:"running"`
+	Enabled bool `json:"enabled"`
+}
+
+type ArchiverStateRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// archiveSettings is the archiver configuration resolved from the
+// environment
+type archiveSettings struct {
+	After     time.Duration
+	Interval  time.Duration
+	BatchSize int
+	Enabled   bool
+}
+
+// loadArchiveSettings reads NEWS_ARCHIVE_AFTER and the variables tuning it.
+// ok is false when archiving is not configured.
+func loadArchiveSettings() (s archiveSettings, ok bool) {
+	v := os.Getenv("NEWS_ARCHIVE_AFTER")
+	if v == "" {
+		return s, false
+	}
+	var err error
+	s.After, err = time.ParseDuration(v)
+	if err != nil || s.After <= 0 {
+		fatalf("Invalid NEWS_ARCHIVE_AFTER %q: must be a positive duration", v)
+	}
+
+	s.Interval = defaultArchiveInterval
+	if v := os.Getenv("NEWS_ARCHIVE_INTERVAL"); v != "" {
+		s.Interval, err = time.ParseDuration(v)
+		if err != nil || s.Interval <= 0 {
+			fatalf("Invalid NEWS_ARCHIVE_INTERVAL %q: must be a positive duration", v)
+		}
+	}
+
+	s.BatchSize = defaultArchiveBatchSize
+	if v := os.Getenv("NEWS_ARCHIVE_BATCH_SIZE"); v != "" {
+		s.BatchSize, err = strconv.Atoi(v)
+		if err != nil || s.BatchSize <= 0 {
+			fatalf("Invalid NEWS_ARCHIVE_BATCH_SIZE %q: must be a positive integer", v)
+		}
+	}
+
+	s.Enabled = true
+	if v := os.Getenv("NEWS_ARCHIVE_ENABLED"); v != "" {
+		s.Enabled, err = strconv.ParseBool(v)
+		if err != nil {
+			fatalf("Invalid NEWS_ARCHIVE_ENABLED %q: must be a boolean", v)
+		}
+	}
+	return s, true
+}
+
+// startArchiver launches the background mover when NEWS_ARCHIVE_AFTER is
+// configured. NEWS_ARCHIVE_INTERVAL and NEWS_ARCHIVE_BATCH_SIZE tune how
+// often it runs and how many rows each transaction moves.
+func startArchiver() {
+	settings, ok := loadArchiveSettings()
+	if !ok {
+		return
+	}
+	after, interval, batchSize, enabled := settings.After, settings.Interval, settings.BatchSize, settings.Enabled
+	archiverEnabled.Store(enabled)
+	archiverRunning.Store(true)
+
+	slog.Info("Archiver started", "after", after.String(), "interval", interval.String(), "enabled", enabled)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if archiverEnabled.Load() {
+				moved, err := archiveNews(clock.Now().Add(-after), batchSize)
+				if err != nil {
+					slog.Error("Error archiving news", "error", err)
+				} else if moved > 0 {
+					slog.Info("Archived news", "moved", moved)
+				}
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// archiveNews moves every article created before cutoff into news_archive,
+// batchSize rows at a time. Each batch is a single DELETE ... RETURNING
+// feeding an INSERT, so a row is always in exactly one of the two tables.
+// Articles with moderation flags stay put: deleting them from news would
+// cascade to the flags before anyone reviewed them. The kill switch is
+// checked before every batch, so turning it off stops a long pass early.
+func archiveNews(cutoff time.Time, batchSize int) (int64, error) {
+	var total int64
+	for {
+		if !archiverEnabled.Load() {
+			return total, nil
+		}
+		res, err := db.Exec(`
+			WITH moved AS (
+				DELETE FROM news
+				WHERE id IN (
+					SELECT id FROM news
+					WHERE created_at < $1
+					AND NOT EXISTS (SELECT 1 FROM moderation_flags f WHERE f.news_id = news.id)
+					ORDER BY id
+					LIMIT $2
+					FOR UPDATE SKIP LOCKED
+				)
+				RETURNING id, uuid, title, content, topic_id, created_at, updated_at, created_by
+			)
+			INSERT INTO news_archive (id, uuid, title, content, topic_id, created_at, updated_at, created_by, archived_at)
+			SELECT id, uuid, title, content, topic_id, created_at, updated_at, created_by, $3
+			FROM moved
+		`, cutoff.UTC(), batchSize, timestamp())
+		if err != nil {
+			return total, err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
+
+// getArchivedNewsById looks an article up in the archive, flagging it as archived
+func getArchivedNewsById(ctx context.Context, id string) (News, error) {
+	var news News
+	err := db.QueryRowContext(ctx, `
+		SELECT id, uuid, title, content, topic_id, created_at, updated_at
+		FROM news_archive
+		WHERE `+idColumn(id)+` = $1
+	`, id).Scan(&news.ID, &news.UUID, &news.Title, &news.Content, &news.TopicID, &news.CreatedAt, &news.UpdatedAt)
+	news.Archived = true
+	return news, err
+}
+
+// getArchivedNews lists the archive newest first, paginated like the live
+// listing: ?limit= with ?offset= or the ?cursor= from the previous page
+func getArchivedNews(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	page, err := parsePage(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidParameter, Message: err.Error()})
+	}
+
+	var total int64
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM news_archive").Scan(&total); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to fetch archived news"})
+	}
+	meta := ListMeta{Total: &total}
+
+	var w where
+	if page.After != nil {
+		w.add("(created_at, id) < (?, ?)", page.After.CreatedAt, page.After.ID)
+	}
+	// One extra row tells us whether there is a next page
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, uuid, title, content, topic_id, created_at, updated_at
+		FROM news_archive
+		`+w.String()+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT `+w.arg(page.Limit+1)+` OFFSET `+w.arg(page.Offset), w.args...)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to fetch archived news"})
+	}
+	defer rows.Close()
+
+	var newsList []News
+	for rows.Next() {
+		news := News{Archived: true}
+		err := rows.Scan(&news.ID, &news.UUID, &news.Title, &news.Content, &news.TopicID, &news.CreatedAt, &news.UpdatedAt)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Error scanning news row"})
+		}
+		newsList = append(newsList, news)
+	}
+	if err := rows.Err(); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to fetch archived news"})
+	}
+
+	if len(newsList) > page.Limit {
+		newsList = newsList[:page.Limit]
+		last := newsList[page.Limit-1]
+		meta.NextCursor = newsCursor{CreatedAt: last.CreatedAt, ID: last.ID}.encode()
+	}
+	return respondListMeta(c, newsList, meta)
+}
+
+func getArchiverState(c echo.Context) error {
+	return c.JSON(http.StatusOK, ArchiverState{
+		Running: archiverRunning.Load(),
+		Enabled: archiverEnabled.Load(),
+	})
+}
+
+// updateArchiverState pauses or resumes the mover. A pass already in
+// progress stops after its current batch when paused.
+func updateArchiverState(c echo.Context) error {
+	body := new(ArchiverStateRequest)
+	if err := c.Bind(body); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidPayload, Message: "Invalid request payload"})
+	}
+	if body.Enabled == nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeValidationFailed, Message: "Enabled is required", Details: map[string]string{"enabled": "required"}})
+	}
+
+	if previous := archiverEnabled.Swap(*body.Enabled); previous != *body.Enabled {
+		requestLog(c.Request().Context()).Info("Archiver enabled changed", "from", previous, "to", *body.Enabled, "remote_ip", c.RealIP())
+	}
+
+	return getArchiverState(c)
+}
@@ -0,0 +1,199 @@
+// archive_test.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// enableArchiver turns the kill switch on for the rest of the test
+func enableArchiver(t *testing.T, enabled bool) {
+	saved := archiverEnabled.Load()
+	archiverEnabled.Store(enabled)
+	t.Cleanup(func() { archiverEnabled.Store(saved) })
+}
+
+// Test that old articles move to the archive and stay reachable by id
+func TestArchiveNews(t *testing.T) {
+	requirePostgres(t)
+	e := setupEcho()
+	enableArchiver(t, true)
+
+	var topicID int
+	err := db.QueryRow(`INSERT INTO topics (name, description) VALUES ('Archive Topic', '') RETURNING id`).Scan(&topicID)
+	assert.NoError(t, err)
+	defer db.Exec("DELETE FROM topics WHERE id = $1", topicID)
+
+	var oldID, freshID int
+	err = db.QueryRow(`
+		INSERT INTO news (title, content, topic_id, created_at, updated_at)
+		VALUES ('Old story', 'From long ago', $1, NOW() - INTERVAL '2 years', NOW() - INTERVAL '2 years')
+		RETURNING id
+	`, topicID).Scan(&oldID)
+	assert.NoError(t, err)
+	err = db.QueryRow(`
+		INSERT INTO news (title, content, topic_id) VALUES ('Fresh story', 'From today', $1) RETURNING id
+	`, topicID).Scan(&freshID)
+	assert.NoError(t, err)
+	defer db.Exec("DELETE FROM news WHERE id = $1", freshID)
+	defer db.Exec("DELETE FROM news_archive WHERE id = $1", oldID)
+
+	// Batch size 1 exercises the multi-batch loop
+	moved, err := archiveNews(time.Now().Add(-365*24*time.Hour), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), moved)
+
+	var inNews, inArchive int
+	db.QueryRow("SELECT COUNT(*) FROM news WHERE id = $1", oldID).Scan(&inNews)
+	db.QueryRow("SELECT COUNT(*) FROM news_archive WHERE id = $1", oldID).Scan(&inArchive)
+	assert.Equal(t, 0, inNews)
+	assert.Equal(t, 1, inArchive)
+
+	// Lookup by id falls back to the archive
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/news/:id")
+	c.SetParamNames("id")
+	c.SetParamValues(strconv.Itoa(oldID))
+
+	assert.NoError(t, getNewsById(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var news News
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &news))
+	assert.Equal(t, oldID, news.ID)
+	assert.True(t, news.Archived)
+
+	// Default listings exclude it, the archive listing includes it
+	req = httptest.NewRequest(http.MethodGet, "/api/news", nil)
+	rec = httptest.NewRecorder()
+	assert.NoError(t, getAllNews(e.NewContext(req, rec)))
+	assert.NotContains(t, rec.Body.String(), "Old story")
+	assert.Contains(t, rec.Body.String(), "Fresh story")
+
+	req = httptest.NewRequest(http.MethodGet, "/api/news/archive", nil)
+	rec = httptest.NewRecorder()
+	assert.NoError(t, getArchivedNews(e.NewContext(req, rec)))
+	assert.Contains(t, rec.Body.String(), "Old story")
+	assert.NotContains(t, rec.Body.String(), "Fresh story")
+	assert.NotEmpty(t, rec.Header().Get("X-Total-Count"))
+
+	// It is paginated like the live listing
+	req = httptest.NewRequest(http.MethodGet, "/api/news/archive?limit=0", nil)
+	rec = httptest.NewRecorder()
+	assert.NoError(t, getArchivedNews(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Archived articles are read-only
+	payload := `{"title":"Edited","content":"Edited","topic_id":` + strconv.Itoa(topicID) + `}`
+	req = httptest.NewRequest(http.MethodPut, "/", bytes.NewBufferString(payload))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetPath("/api/news/:id")
+	c.SetParamNames("id")
+	c.SetParamValues(strconv.Itoa(oldID))
+
+	assert.NoError(t, updateNews(c))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	// The topic still counts as in use
+	req = httptest.NewRequest(http.MethodDelete, "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetPath("/api/topics/:id")
+	c.SetParamNames("id")
+	c.SetParamValues(strconv.Itoa(topicID))
+
+	assert.NoError(t, deleteTopic(c))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	// Deleting by id reaches into the archive
+	req = httptest.NewRequest(http.MethodDelete, "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetPath("/api/news/:id")
+	c.SetParamNames("id")
+	c.SetParamValues(strconv.Itoa(oldID))
+
+	assert.NoError(t, deleteNews(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+// With the kill switch off a pass stops before its first batch
+func TestArchiveNewsStopsWhenDisabled(t *testing.T) {
+	enableArchiver(t, false)
+	moved, err := archiveNews(time.Now(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), moved)
+}
+
+// Flagged articles wait for review in news, since moving them would
+// cascade away their moderation flags
+func TestArchiveNewsKeepsFlagged(t *testing.T) {
+	requirePostgres(t)
+	enableArchiver(t, true)
+	var topicID, newsID int
+	err := db.QueryRow(`INSERT INTO topics (name, description) VALUES ('Flagged Archive Topic', '') RETURNING id`).Scan(&topicID)
+	assert.NoError(t, err)
+	defer db.Exec("DELETE FROM topics WHERE id = $1", topicID)
+	err = db.QueryRow(`
+		INSERT INTO news (title, content, topic_id, created_at, updated_at)
+		VALUES ('Flagged old story', 'Awaiting review', $1, NOW() - INTERVAL '2 years', NOW() - INTERVAL '2 years')
+		RETURNING id
+	`, topicID).Scan(&newsID)
+	assert.NoError(t, err)
+	defer db.Exec("DELETE FROM news WHERE id = $1", newsID)
+	_, err = db.Exec(`INSERT INTO moderation_flags (news_id, reasons) VALUES ($1, '{spam}')`, newsID)
+	assert.NoError(t, err)
+
+	_, err = archiveNews(time.Now().Add(-365*24*time.Hour), 10)
+	assert.NoError(t, err)
+
+	var inNews, flags int
+	db.QueryRow("SELECT COUNT(*) FROM news WHERE id = $1", newsID).Scan(&inNews)
+	db.QueryRow("SELECT COUNT(*) FROM moderation_flags WHERE news_id = $1", newsID).Scan(&flags)
+	assert.Equal(t, 1, inNews)
+	assert.Equal(t, 1, flags)
+}
+
+func TestArchiverKillSwitch(t *testing.T) {
+	e := setupEcho()
+	defer archiverEnabled.Store(archiverEnabled.Load())
+	archiverEnabled.Store(true)
+
+	update := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/admin/archiver", bytes.NewBufferString(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, updateArchiverState(e.NewContext(req, rec)))
+		return rec
+	}
+
+	rec := update(`{"enabled":false}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"running":false,"enabled":false}`, rec.Body.String())
+	assert.False(t, archiverEnabled.Load())
+
+	rec = update(`{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.False(t, archiverEnabled.Load())
+
+	rec = update(`{"enabled":true}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, archiverEnabled.Load())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/archiver", nil)
+	rec = httptest.NewRecorder()
+	assert.NoError(t, getArchiverState(e.NewContext(req, rec)))
+	assert.JSONEq(t, `{"running":false,"enabled":true}`, rec.Body.String())
+}
@@ -0,0 +1,319 @@
+// auth.go
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// jwtSecret signs and verifies bearer tokens (HS256). It is nil only when
+// AUTH_DISABLED=true stands in for JWT_SECRET, and writes and /api/admin
+// are then open to anyone.
+var jwtSecret []byte
+
+// minJWTSecretBytes matches the HMAC-SHA256 key size
+const minJWTSecretBytes = 32
+
+const defaultTokenTTL = 24 * time.Hour
+
+// tokenTTL is how long a token from /api/auth/login stays valid
+var tokenTTL = defaultTokenTTL
+
+// loadAuthSettings reads JWT_SECRET and JWT_TTL. Running without a
+// secret has to be asked for with AUTH_DISABLED=true, so a deployment that
+// forgot it does not silently serve unauthenticated writes.
+func loadAuthSettings() {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		if os.Getenv("AUTH_DISABLED") != "true" {
+			fatal("JWT_SECRET is not set; set it, or set AUTH_DISABLED=true to run with writes open to anyone")
+		}
+		jwtSecret = nil
+		slog.Warn("AUTH_DISABLED=true: writes and /api/admin are open to anyone without a token")
+		return
+	}
+	if len(secret) < minJWTSecretBytes {
+		fatalf("JWT_SECRET must be at least %d bytes", minJWTSecretBytes)
+	}
+	jwtSecret = []byte(secret)
+	if v := os.Getenv("JWT_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			fatalf("Invalid JWT_TTL %q: must be a positive duration", v)
+		}
+		tokenTTL = d
+	}
+}
+
+// Password limits. bcrypt ignores everything past 72 bytes, so longer
+// passwords are refused rather than silently truncated.
+const (
+	minPasswordBytes = 8
+	maxPasswordBytes = 72
+	maxEmailChars    = 254
+)
+
+type Credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type User struct {
+	ID        int       `json:"id"`
+	Email     string    `json:"email"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type TokenResponse struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// normalizeEmail lower-cases a bare address, reporting false for anything
+// else, such as a display name or a missing domain
+func normalizeEmail(email string) (string, bool) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	addr, err := mail.ParseAddress(email)
+	return email, err == nil && addr.Address == email && len(email) <= maxEmailChars
+}
+
+// credentialProblems returns Details for an unusable registration
+func credentialProblems(email, password string) map[string]string {
+	details := map[string]string{}
+	if _, ok := normalizeEmail(email); !ok {
+		details["email"] = "must be a valid email address"
+	}
+	if len(password) < minPasswordBytes || len(password) > maxPasswordBytes {
+		details["password"] = "must be " + strconv.Itoa(minPasswordBytes) + " to " + strconv.Itoa(maxPasswordBytes) + " bytes"
+	}
+	return details
+}
+
+func registerUser(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	body := new(Credentials)
+	if err := c.Bind(body); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidPayload, Message: "Invalid request payload"})
+	}
+	if details := credentialProblems(body.Email, body.Password); len(details) > 0 {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeValidationFailed, Message: "Invalid email or password", Details: details})
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(body.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to register user"})
+	}
+	email, _ := normalizeEmail(body.Email)
+	user := User{Email: email, CreatedAt: timestamp()}
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO users (email, password_hash, created_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, role
+	`, user.Email, string(hash), user.CreatedAt).Scan(&user.ID, &user.Role)
+	if err != nil {
+		return respondError(c, err, "User", "Failed to register user")
+	}
+	return c.JSON(http.StatusCreated, user)
+}
+
+// dummyHash is compared against when the email is unknown, so a login
+// takes as long whether or not the account exists
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// loginUser exchanges an email and password for a bearer token. Unknown
+// emails and wrong passwords get the same answer.
+func loginUser(c echo.Context) error {
+	if jwtSecret == nil {
+		return c.JSON(http.StatusNotImplemented, ErrorResponse{Code: codeNotImplemented, Message: "Login is disabled until JWT_SECRET is set"})
+	}
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	body := new(Credentials)
+	if err := c.Bind(body); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidPayload, Message: "Invalid request payload"})
+	}
+
+	email, _ := normalizeEmail(body.Email)
+	var p principal
+	var hash string
+	err := db.QueryRowContext(ctx, "SELECT id, role, password_hash FROM users WHERE email = $1", email).Scan(&p.UserID, &p.Role, &hash)
+	if err == sql.ErrNoRows {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(body.Password))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Code: codeInvalidCredentials, Message: "Invalid email or password"})
+	} else if err != nil {
+		return respondError(c, err, "User", "Failed to log in")
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(body.Password)) != nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Code: codeInvalidCredentials, Message: "Invalid email or password"})
+	}
+
+	token, expires := signToken(p, clock.Now())
+	return c.JSON(http.StatusOK, TokenResponse{AccessToken: token, TokenType: "Bearer", ExpiresAt: expires})
+}
+
+// Token errors. Both answer 401; the codes tell a client whether logging
+// in again will help.
+var (
+	errTokenExpired = errors.New("token expired")
+	errTokenInvalid = errors.New("token invalid")
+)
+
+type tokenClaims struct {
+	Subject   string `json:"sub"`
+	Role      Role   `json:"role,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// principal is who a token was issued to
+type principal struct {
+	UserID int
+	Role   Role
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// signedTokenHeader is the only header issued or accepted
+var signedTokenHeader = func() string {
+	header, _ := json.Marshal(tokenHeader{Alg: "HS256", Typ: "JWT"})
+	return b64.EncodeToString(header)
+}()
+
+func tokenSignature(unsigned string) []byte {
+	mac := hmac.New(sha256.New, jwtSecret)
+	mac.Write([]byte(unsigned))
+	return mac.Sum(nil)
+}
+
+// signToken issues a token for p, returning it with its expiry, which is
+// kept to the second as the exp claim is
+func signToken(p principal, now time.Time) (string, time.Time) {
+	expires := now.Add(tokenTTL).Truncate(time.Second).UTC()
+	claims, _ := json.Marshal(tokenClaims{Subject: strconv.Itoa(p.UserID), Role: p.Role, IssuedAt: now.Unix(), ExpiresAt: expires.Unix()})
+	unsigned := signedTokenHeader + "." + b64.EncodeToString(claims)
+	return unsigned + "." + b64.EncodeToString(tokenSignature(unsigned)), expires
+}
+
+// parseToken verifies a token and returns who it was issued to. The
+// signature is checked before expiry, so only tokens this server issued
+// can be reported as expired. Tokens from before roles existed are
+// readers'.
+func parseToken(token string, now time.Time) (principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return principal{}, errTokenInvalid
+	}
+	var header tokenHeader
+	if raw, err := b64.DecodeString(parts[0]); err != nil || json.Unmarshal(raw, &header) != nil || header.Alg != "HS256" {
+		return principal{}, errTokenInvalid
+	}
+	signature, err := b64.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, tokenSignature(parts[0]+"."+parts[1])) {
+		return principal{}, errTokenInvalid
+	}
+
+	var claims tokenClaims
+	if raw, err := b64.DecodeString(parts[1]); err != nil || json.Unmarshal(raw, &claims) != nil {
+		return principal{}, errTokenInvalid
+	}
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil || userID <= 0 || claims.ExpiresAt == 0 {
+		return principal{}, errTokenInvalid
+	}
+	if claims.Role == "" {
+		claims.Role = roleReader
+	} else if !validRole(claims.Role) {
+		return principal{}, errTokenInvalid
+	}
+	if !now.Before(time.Unix(claims.ExpiresAt, 0)) {
+		return principal{}, errTokenExpired
+	}
+	return principal{UserID: userID, Role: claims.Role}, nil
+}
+
+type principalKey struct{}
+
+// currentPrincipal returns who the request's bearer token was issued to;
+// false when the request carried none because auth is disabled
+func currentPrincipal(ctx context.Context) (principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(principal)
+	return p, ok
+}
+
+// currentUser returns the user id from the request's bearer token, or nil
+func currentUser(ctx context.Context) *int {
+	p, ok := currentPrincipal(ctx)
+	if !ok {
+		return nil
+	}
+	return &p.UserID
+}
+
+// needsToken reports whether a request must carry a bearer token: every
+// request under /api/admin, and every write under /api except the
+// /api/auth endpoints that issue them
+func needsToken(method, path string) bool {
+	switch {
+	case method == http.MethodOptions:
+		return false
+	case strings.HasPrefix(path, "/api/admin/"):
+		return true
+	case method == http.MethodGet || method == http.MethodHead:
+		return false
+	}
+	return strings.HasPrefix(path, "/api/") && !strings.HasPrefix(path, "/api/auth/")
+}
+
+// authenticate verifies the bearer token wherever needsToken says one is
+// required and stores who it was issued to for currentPrincipal. It is
+// bypassed only when AUTH_DISABLED=true.
+func authenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		if jwtSecret == nil || !needsToken(req.Method, req.URL.Path) {
+			return next(c)
+		}
+		token, ok := strings.CutPrefix(req.Header.Get(echo.HeaderAuthorization), "Bearer ")
+		if !ok || token == "" {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+			return c.JSON(http.StatusUnauthorized, ErrorResponse{Code: codeUnauthorized, Message: "Missing bearer token"})
+		}
+		p, err := parseToken(token, clock.Now())
+		if err != nil {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			if errors.Is(err, errTokenExpired) {
+				return c.JSON(http.StatusUnauthorized, ErrorResponse{Code: codeTokenExpired, Message: "Bearer token has expired, log in again"})
+			}
+			return c.JSON(http.StatusUnauthorized, ErrorResponse{Code: codeTokenInvalid, Message: "Bearer token is malformed or its signature is invalid"})
+		}
+		c.SetRequest(req.WithContext(context.WithValue(req.Context(), principalKey{}, p)))
+		return next(c)
+	}
+}
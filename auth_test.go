@@ -0,0 +1,241 @@
+// auth_test.go
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testJWTSecret = "test-jwt-secret-of-at-least-32-bytes"
+
+func useJWTSecret(t *testing.T, secret string) {
+	saved := jwtSecret
+	jwtSecret = []byte(secret)
+	if secret == "" {
+		jwtSecret = nil
+	}
+	t.Cleanup(func() { jwtSecret = saved })
+}
+
+// testUserID is a user tokens can be signed for: a real row when there is
+// a database, since news.created_by references users there
+func testUserID(t *testing.T) int {
+	if db == nil {
+		return 1
+	}
+	email := strings.ToLower(strings.ReplaceAll(t.Name(), "/", "-")) + "@example.com"
+	db.Exec("DELETE FROM users WHERE email = $1", email)
+	var id int
+	if err := db.QueryRow("INSERT INTO users (email, password_hash) VALUES ($1, 'x') RETURNING id", email).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM users WHERE id = $1", id) })
+	return id
+}
+
+// Without JWT_SECRET auth only stays off when asked to, and says so
+func TestLoadAuthSettingsDisabled(t *testing.T) {
+	useJWTSecret(t, testJWTSecret)
+	buf := captureLogs(t)
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("AUTH_DISABLED", "true")
+	loadAuthSettings()
+
+	assert.Nil(t, jwtSecret)
+	if entries := logEntries(t, buf); assert.Len(t, entries, 1) {
+		assert.Equal(t, "WARN", entries[0]["level"])
+		assert.Contains(t, entries[0]["msg"], "AUTH_DISABLED=true")
+	}
+}
+
+func TestParseToken(t *testing.T) {
+	useJWTSecret(t, testJWTSecret)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+
+	token, expires := signToken(principal{UserID: 42, Role: roleEditor}, now)
+	assert.Equal(t, time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC), expires)
+	p, err := parseToken(token, now)
+	assert.NoError(t, err)
+	assert.Equal(t, principal{UserID: 42, Role: roleEditor}, p)
+	_, err = parseToken(token, expires.Add(-time.Second))
+	assert.NoError(t, err)
+	_, err = parseToken(token, expires)
+	assert.Equal(t, errTokenExpired, err)
+
+	parts := strings.Split(token, ".")
+	forged := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"1","exp":9999999999}`)) + "." + parts[2]
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + parts[1] + "."
+	useJWTSecret(t, "another-secret-of-at-least-32-bytes!")
+	other, _ := signToken(principal{UserID: 42, Role: roleAdmin}, now)
+	useJWTSecret(t, testJWTSecret)
+
+	// Tokens issued before roles are readers'; unknown roles are refused
+	legacy, _ := signToken(principal{UserID: 42}, now)
+	p, err = parseToken(legacy, now)
+	assert.NoError(t, err)
+	assert.Equal(t, roleReader, p.Role)
+	owner, _ := signToken(principal{UserID: 42, Role: "owner"}, now)
+
+	for name, bad := range map[string]string{
+		"forged claims":  forged,
+		"alg none":       unsigned,
+		"other secret":   other,
+		"unknown role":   owner,
+		"not a token":    "not-a-token",
+		"empty":          "",
+		"bad encoding":   "a.b.c",
+		"extra segment":  token + ".x",
+		"bad signature":  parts[0] + "." + parts[1] + "." + parts[1],
+		"expired forged": parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"42","exp":1}`)) + "." + parts[2],
+	} {
+		_, err := parseToken(bad, now)
+		assert.Equal(t, errTokenInvalid, err, name)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	useStore(t, newMemStore())
+	useJWTSecret(t, testJWTSecret)
+	fc := useFakeClock(t, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	s := newTestServer(t)
+	topic := map[string]string{"name": uniqueName(t, "Auth")}
+
+	// Reads stay public
+	s.expect(s.do(http.MethodGet, "/api/topics", nil), http.StatusOK, nil)
+
+	var body ErrorResponse
+	rec := s.do(http.MethodPost, "/api/topics", topic)
+	if s.expect(rec, http.StatusUnauthorized, &body) {
+		assert.Equal(t, codeUnauthorized, body.Code)
+		assert.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+	}
+
+	s.token = "not-a-token"
+	body = ErrorResponse{}
+	if s.expect(s.do(http.MethodPost, "/api/topics", topic), http.StatusUnauthorized, &body) {
+		assert.Equal(t, codeTokenInvalid, body.Code)
+	}
+
+	s.token, _ = signToken(principal{UserID: 7, Role: roleAdmin}, fc.Now().Add(-tokenTTL))
+	body = ErrorResponse{}
+	rec = s.do(http.MethodDelete, "/api/topics/1", nil)
+	if s.expect(rec, http.StatusUnauthorized, &body) {
+		assert.Equal(t, codeTokenExpired, body.Code)
+		assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "invalid_token")
+	}
+
+	s.token, _ = signToken(principal{UserID: 7, Role: roleAdmin}, fc.Now())
+	var created Topic
+	mustCreate(t, s, "/api/topics", topic, &created, func() string { return "/api/topics/" + strconv.Itoa(created.ID) })
+
+	// Disabled auth lets writes through again
+	useJWTSecret(t, "")
+	open := newTestServer(t)
+	open.expect(open.do(http.MethodPatch, "/api/topics/"+strconv.Itoa(created.ID), map[string]string{"tagline": "Open"}), http.StatusOK, nil)
+}
+
+func TestNewsCreatedBy(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s *testServer) {
+		useJWTSecret(t, testJWTSecret)
+		userID := testUserID(t)
+		s.token, _ = signToken(principal{UserID: userID, Role: roleAdmin}, clock.Now())
+
+		var topic Topic
+		if !mustCreate(t, s, "/api/topics", map[string]string{"name": uniqueName(t, "Created by")}, &topic, func() string { return "/api/topics/" + strconv.Itoa(topic.ID) }) {
+			return
+		}
+		// created_by in the body is ignored
+		var news News
+		article := map[string]interface{}{"title": "By a user", "content": "Body", "topic_id": topic.ID, "created_by": userID + 1}
+		if !mustCreate(t, s, "/api/news", article, &news, func() string { return "/api/news/" + strconv.Itoa(news.ID) }) {
+			return
+		}
+		path := "/api/news/" + strconv.Itoa(news.ID)
+		if assert.NotNil(t, news.CreatedBy) {
+			assert.Equal(t, userID, *news.CreatedBy)
+		}
+
+		var got News
+		s.expect(s.do(http.MethodGet, path, nil), http.StatusOK, &got)
+		assert.Equal(t, news.CreatedBy, got.CreatedBy)
+		var sparse map[string]interface{}
+		s.expect(s.do(http.MethodGet, path+"?fields=id,created_by", nil), http.StatusOK, &sparse)
+		assert.EqualValues(t, userID, sparse["created_by"])
+
+		// Updates keep the creator
+		s.expect(s.do(http.MethodPatch, path, map[string]string{"title": "Edited"}), http.StatusOK, &got)
+		assert.Equal(t, news.CreatedBy, got.CreatedBy)
+	})
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	requirePostgres(t)
+	useJWTSecret(t, testJWTSecret)
+	fc := useFakeClock(t, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	s := newTestServer(t)
+	email := "register-and-login@example.com"
+	db.Exec("DELETE FROM users WHERE email = $1", email)
+	t.Cleanup(func() { db.Exec("DELETE FROM users WHERE email = $1", email) })
+
+	var body ErrorResponse
+	if s.expect(s.do(http.MethodPost, "/api/auth/register", Credentials{Email: "Reader <" + email + ">", Password: "short"}), http.StatusBadRequest, &body) {
+		assert.Equal(t, codeValidationFailed, body.Code)
+		assert.Equal(t, map[string]string{"email": "must be a valid email address", "password": "must be 8 to 72 bytes"}, body.Details)
+	}
+
+	var user User
+	if !s.expect(s.do(http.MethodPost, "/api/auth/register", Credentials{Email: " Register-And-Login@Example.com", Password: "correct horse"}), http.StatusCreated, &user) {
+		return
+	}
+	assert.Equal(t, email, user.Email)
+	assert.Equal(t, roleReader, user.Role)
+	assert.NotZero(t, user.ID)
+
+	body = ErrorResponse{}
+	if s.expect(s.do(http.MethodPost, "/api/auth/register", Credentials{Email: email, Password: "battery staple"}), http.StatusConflict, &body) {
+		assert.Equal(t, codeDuplicate, body.Code)
+		assert.Equal(t, "A user with this email already exists", body.Message)
+	}
+
+	for _, creds := range []Credentials{{Email: email, Password: "wrong password"}, {Email: "nobody@example.com", Password: "correct horse"}} {
+		body = ErrorResponse{}
+		if s.expect(s.do(http.MethodPost, "/api/auth/login", creds), http.StatusUnauthorized, &body) {
+			assert.Equal(t, codeInvalidCredentials, body.Code)
+		}
+	}
+
+	// Topics need an admin
+	db.Exec("UPDATE users SET role = 'admin' WHERE id = $1", user.ID)
+	var token TokenResponse
+	if !s.expect(s.do(http.MethodPost, "/api/auth/login", Credentials{Email: "REGISTER-and-login@example.com", Password: "correct horse"}), http.StatusOK, &token) {
+		return
+	}
+	assert.Equal(t, "Bearer", token.TokenType)
+	assert.True(t, fc.Now().Add(tokenTTL).Equal(token.ExpiresAt))
+
+	// The token's subject is recorded as the creator
+	s.token = token.AccessToken
+	var topic Topic
+	var news News
+	article := map[string]interface{}{"title": "Registered", "content": "Body"}
+	if mustCreate(t, s, "/api/topics", map[string]string{"name": uniqueName(t, "Registered")}, &topic, func() string { return "/api/topics/" + strconv.Itoa(topic.ID) }) {
+		article["topic_id"] = topic.ID
+	}
+	if mustCreate(t, s, "/api/news", article, &news, func() string { return "/api/news/" + strconv.Itoa(news.ID) }) {
+		var createdBy int
+		assert.NoError(t, db.QueryRow("SELECT created_by FROM news WHERE id = $1", news.ID).Scan(&createdBy))
+		assert.Equal(t, user.ID, createdBy)
+	}
+
+	useJWTSecret(t, "")
+	body = ErrorResponse{}
+	if s.expect(s.do(http.MethodPost, "/api/auth/login", Credentials{Email: email, Password: "correct horse"}), http.StatusNotImplemented, &body) {
+		assert.Equal(t, codeNotImplemented, body.Code)
+	}
+}
@@ -0,0 +1,24 @@
+// clock.go
+package main
+
+import "time"
+
+// Clock is the source of the current time for every time-dependent
+// feature, so tests can substitute a fixed or manually advanced clock.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// Active clock; tests replace it with a fake
+var clock Clock = realClock{}
+
+// timestamp returns the clock's current time in UTC, truncated to the
+// microsecond precision Postgres stores, so the value written to a
+// created_at/updated_at column is exactly the value returned to clients.
+func timestamp() time.Time {
+	return clock.Now().UTC().Truncate(time.Microsecond)
+}
@@ -0,0 +1,101 @@
+// clock_test.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a manually advanced Clock for deterministic tests
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// useFakeClock installs a fake clock for the duration of the test
+func useFakeClock(t *testing.T, now time.Time) *fakeClock {
+	fc := &fakeClock{now: now}
+	prev := clock
+	clock = fc
+	t.Cleanup(func() { clock = prev })
+	return fc
+}
+
+func TestTimestampUsesClock(t *testing.T) {
+	loc := time.FixedZone("WIB", 7*60*60)
+	fc := useFakeClock(t, time.Date(2024, 2, 14, 9, 30, 0, 123456789, loc))
+
+	ts := timestamp()
+	assert.Equal(t, time.UTC, ts.Location())
+	assert.Equal(t, time.Date(2024, 2, 14, 2, 30, 0, 123456000, time.UTC), ts)
+
+	fc.Advance(time.Hour)
+	assert.Equal(t, time.Date(2024, 2, 14, 3, 30, 0, 123456000, time.UTC), timestamp())
+}
+
+func TestHealthCheckUsesClock(t *testing.T) {
+	useFakeClock(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	e := setupEcho()
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	rec := httptest.NewRecorder()
+	assert.NoError(t, healthCheck(e.NewContext(req, rec)))
+
+	var response HealthResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "2024-01-01T00:00:00Z", response.Time)
+}
+
+// Test that stored timestamps come from the clock rather than NOW()
+func TestTopicTimestampsUseClock(t *testing.T) {
+	requirePostgres(t)
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	fc := useFakeClock(t, created)
+	e := setupEcho()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/topics", bytes.NewBufferString(`{"name":"Clock Topic"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	assert.NoError(t, createTopic(e.NewContext(req, rec)))
+
+	var topic Topic
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &topic))
+	defer db.Exec("DELETE FROM topics WHERE id = $1", topic.ID)
+	assert.True(t, created.Equal(topic.CreatedAt))
+	assert.True(t, created.Equal(topic.UpdatedAt))
+
+	fc.Advance(90 * time.Minute)
+	req = httptest.NewRequest(http.MethodPut, "/", bytes.NewBufferString(`{"name":"Clock Topic","description":"later"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/topics/:id")
+	c.SetParamNames("id")
+	c.SetParamValues(topic.UUID)
+	assert.NoError(t, updateTopic(c))
+
+	var updated Topic
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+	assert.True(t, created.Equal(updated.CreatedAt))
+	assert.True(t, created.Add(90*time.Minute).Equal(updated.UpdatedAt))
+}
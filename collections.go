@@ -0,0 +1,468 @@
+// collections.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Collection groups articles into an ordered series, e.g. the parts of a
+// multi-part investigation
+type Collection struct {
+	ID          int       `json:"id"`
+	Title       string    `json:"title"`
+	Slug        string    `json:"slug"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CollectionItem is one article in a collection with links to its
+// neighbours, so pages can render "Part 3 of 5" with prev/next
+type CollectionItem struct {
+	Position int       `json:"position"`
+	News     News      `json:"news"`
+	Prev     *NewsStub `json:"prev"`
+	Next     *NewsStub `json:"next"`
+}
+
+type CollectionDetail struct {
+	Collection
+	Total int              `json:"total"`
+	Items []CollectionItem `json:"items"`
+}
+
+type collectionNewsRequest struct {
+	NewsID   int `json:"news_id"`
+	Position int `json:"position"`
+}
+
+type collectionOrderRequest struct {
+	NewsIDs []int `json:"news_ids"`
+}
+
+// loadCollectionItems returns the collection's articles in order, archived
+// ones included, with prev/next stubs filled in
+func loadCollectionItems(ctx context.Context, collectionID int) ([]CollectionItem, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT n.id, n.uuid, n.title, n.content, n.topic_id, n.created_at, n.updated_at, n.archived
+		FROM news_collections nc
+		JOIN (
+			SELECT id, uuid, title, content, topic_id, created_at, updated_at, FALSE AS archived FROM news
+			UNION ALL
+			SELECT id, uuid, title, content, topic_id, created_at, updated_at, TRUE AS archived FROM news_archive
+		) n ON n.id = nc.news_id
+		WHERE nc.collection_id = $1
+		ORDER BY nc.position, nc.news_id
+	`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []CollectionItem{}
+	for rows.Next() {
+		var news News
+		err := rows.Scan(&news.ID, &news.UUID, &news.Title, &news.Content, &news.TopicID, &news.CreatedAt, &news.UpdatedAt, &news.Archived)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, CollectionItem{Position: len(items) + 1, News: news})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		if i > 0 {
+			prev := items[i-1].News
+			items[i].Prev = &NewsStub{ID: prev.ID, UUID: prev.UUID, Title: prev.Title}
+		}
+		if i < len(items)-1 {
+			next := items[i+1].News
+			items[i].Next = &NewsStub{ID: next.ID, UUID: next.UUID, Title: next.Title}
+		}
+	}
+	return items, nil
+}
+
+// removeFromCollections drops a deleted article from every collection it
+// belonged to, shifting later items up so positions stay contiguous
+func removeFromCollections(ctx context.Context, tx *sql.Tx, newsID int) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE news_collections nc SET position = nc.position - 1
+		FROM news_collections d
+		WHERE d.news_id = $1 AND nc.collection_id = d.collection_id AND nc.position > d.position
+	`, newsID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM news_collections WHERE news_id = $1", newsID)
+	return err
+}
+
+func getCollection(ctx context.Context, slug string) (Collection, error) {
+	var collection Collection
+	err := db.QueryRowContext(ctx, `
+		SELECT id, title, slug, description, created_at, updated_at
+		FROM collections
+		WHERE slug = $1
+	`, slug).Scan(&collection.ID, &collection.Title, &collection.Slug, &collection.Description, &collection.CreatedAt, &collection.UpdatedAt)
+	return collection, err
+}
+
+// lockCollection fetches a collection's id inside tx, locking the row so
+// concurrent membership changes are applied one at a time
+func lockCollection(ctx context.Context, tx *sql.Tx, slug string) (int, error) {
+	var id int
+	err := tx.QueryRowContext(ctx, "SELECT id FROM collections WHERE slug = $1 FOR UPDATE", slug).Scan(&id)
+	return id, err
+}
+
+func respondCollectionDetail(c echo.Context, status int, collection Collection) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	items, err := loadCollectionItems(ctx, collection.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to fetch collection news"})
+	}
+	return c.JSON(status, CollectionDetail{Collection: collection, Total: len(items), Items: items})
+}
+
+// Collection handlers
+func getAllCollections(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	if applyMinimal(c) {
+		return listMinimal(c, "SELECT id, '', slug, updated_at FROM collections ORDER BY title", "Failed to fetch collections")
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, title, slug, description, created_at, updated_at
+		FROM collections
+		ORDER BY title
+	`)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to fetch collections"})
+	}
+	defer rows.Close()
+
+	var collections []Collection
+	for rows.Next() {
+		var collection Collection
+		err := rows.Scan(&collection.ID, &collection.Title, &collection.Slug, &collection.Description, &collection.CreatedAt, &collection.UpdatedAt)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Error scanning collection row"})
+		}
+		collections = append(collections, collection)
+	}
+
+	return respondList(c, collections)
+}
+
+func getCollectionBySlug(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	collection, err := getCollection(ctx, slugParam(c))
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Code: codeCollectionNotFound, Message: "Collection not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to fetch collection"})
+	}
+
+	return respondCollectionDetail(c, http.StatusOK, collection)
+}
+
+func createCollection(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	collection := new(Collection)
+	if err := c.Bind(collection); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidPayload, Message: "Invalid request payload"})
+	}
+
+	// Validate required fields, deriving the slug from the title if omitted
+	if collection.Title == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeValidationFailed, Message: "Collection title is required", Details: map[string]string{"title": "required"}})
+	}
+	if collection.Slug == "" {
+		collection.Slug = slugify(collection.Title)
+	}
+	if !validSlug(collection.Slug) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeValidationFailed, Message: "Slug must contain only lowercase letters, digits and single hyphens", Details: map[string]string{"slug": "must contain only lowercase letters, digits and single hyphens"}})
+	}
+
+	now := timestamp()
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO collections (title, slug, description, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		RETURNING id, created_at, updated_at
+	`, collection.Title, collection.Slug, collection.Description, now).Scan(&collection.ID, &collection.CreatedAt, &collection.UpdatedAt)
+
+	if err != nil {
+		return respondError(c, err, "Collection", "Failed to create collection")
+	}
+
+	return c.JSON(http.StatusCreated, collection)
+}
+
+func updateCollection(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	slug := slugParam(c)
+	collection := new(Collection)
+	if err := c.Bind(collection); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidPayload, Message: "Invalid request payload"})
+	}
+
+	// Validate required fields; the slug is kept unless a new one is given
+	if collection.Title == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeValidationFailed, Message: "Collection title is required", Details: map[string]string{"title": "required"}})
+	}
+	if collection.Slug == "" {
+		collection.Slug = slug
+	}
+	if !validSlug(collection.Slug) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeValidationFailed, Message: "Slug must contain only lowercase letters, digits and single hyphens", Details: map[string]string{"slug": "must contain only lowercase letters, digits and single hyphens"}})
+	}
+
+	err := db.QueryRowContext(ctx, `
+		UPDATE collections
+		SET title = $1, slug = $2, description = $3, updated_at = $4
+		WHERE slug = $5
+		RETURNING id, title, slug, description, created_at, updated_at
+	`, collection.Title, collection.Slug, collection.Description, timestamp(), slug).Scan(&collection.ID, &collection.Title, &collection.Slug, &collection.Description, &collection.CreatedAt, &collection.UpdatedAt)
+
+	if err != nil {
+		return respondError(c, err, "Collection", "Failed to update collection")
+	}
+
+	return c.JSON(http.StatusOK, collection)
+}
+
+func deleteCollection(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	res, err := db.ExecContext(ctx, "DELETE FROM collections WHERE slug = $1", slugParam(c))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to delete collection"})
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Error checking delete result"})
+	}
+	if rowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Code: codeCollectionNotFound, Message: "Collection not found"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Collection deleted successfully"})
+}
+
+// addCollectionNews adds an article at the given 1-based position,
+// shifting later items down, or appends it when no position is given
+func addCollectionNews(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	slug := slugParam(c)
+	body := new(collectionNewsRequest)
+	if err := c.Bind(body); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidPayload, Message: "Invalid request payload"})
+	}
+	if body.Position < 0 {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeValidationFailed, Message: "Position must be positive", Details: map[string]string{"position": "must be positive"}})
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to add news to collection"})
+	}
+	defer tx.Rollback()
+
+	collectionID, err := lockCollection(ctx, tx, slug)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Code: codeCollectionNotFound, Message: "Collection not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to add news to collection"})
+	}
+
+	// Verify news exists, archived articles included
+	var newsExists bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM news WHERE id = $1)
+			OR EXISTS(SELECT 1 FROM news_archive WHERE id = $1)
+	`, body.NewsID).Scan(&newsExists)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Error verifying news"})
+	}
+	if !newsExists {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidReference, Message: "News does not exist", Details: map[string]string{"news_id": "does not exist"}})
+	}
+
+	var count int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM news_collections WHERE collection_id = $1", collectionID).Scan(&count); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to add news to collection"})
+	}
+	position := body.Position
+	if position == 0 || position > count {
+		position = count + 1
+	} else {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE news_collections SET position = position + 1
+			WHERE collection_id = $1 AND position >= $2
+		`, collectionID, position)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to add news to collection"})
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO news_collections (collection_id, news_id, position)
+		VALUES ($1, $2, $3)
+	`, collectionID, body.NewsID, position)
+	if isUniqueViolation(err) {
+		return c.JSON(http.StatusConflict, ErrorResponse{Code: codeDuplicate, Message: "News is already in this collection"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to add news to collection"})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to add news to collection"})
+	}
+
+	collection, err := getCollection(ctx, slug)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to fetch collection"})
+	}
+	return respondCollectionDetail(c, http.StatusOK, collection)
+}
+
+// removeCollectionNews removes an article and closes the gap it leaves
+func removeCollectionNews(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	slug := slugParam(c)
+	newsID := c.Param("news_id")
+	if !validID(newsID) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidParameter, Message: "Invalid news id"})
+	}
+	// Memberships are kept by integer id, so a UUID is looked up first
+	newsRef := "$2"
+	if isUUID(newsID) {
+		newsRef = "SELECT id FROM news WHERE uuid = $2 UNION ALL SELECT id FROM news_archive WHERE uuid = $2"
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to remove news from collection"})
+	}
+	defer tx.Rollback()
+
+	collectionID, err := lockCollection(ctx, tx, slug)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Code: codeCollectionNotFound, Message: "Collection not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to remove news from collection"})
+	}
+
+	var position int
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM news_collections
+		WHERE collection_id = $1 AND news_id IN (`+newsRef+`)
+		RETURNING position
+	`, collectionID, newsID).Scan(&position)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Code: codeNewsNotInCollection, Message: "News is not in this collection"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to remove news from collection"})
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE news_collections SET position = position - 1
+		WHERE collection_id = $1 AND position > $2
+	`, collectionID, position)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to remove news from collection"})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to remove news from collection"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "News removed from collection successfully"})
+}
+
+// reorderCollectionNews replaces the order of a collection's articles. The
+// request must list exactly the current members, each once.
+func reorderCollectionNews(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	slug := slugParam(c)
+	body := new(collectionOrderRequest)
+	if err := c.Bind(body); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidPayload, Message: "Invalid request payload"})
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to reorder collection"})
+	}
+	defer tx.Rollback()
+
+	collectionID, err := lockCollection(ctx, tx, slug)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Code: codeCollectionNotFound, Message: "Collection not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to reorder collection"})
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT news_id FROM news_collections WHERE collection_id = $1", collectionID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to reorder collection"})
+	}
+	members := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to reorder collection"})
+		}
+		members[id] = true
+	}
+	rows.Close()
+
+	seen := map[int]bool{}
+	for _, id := range body.NewsIDs {
+		if !members[id] || seen[id] {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeValidationFailed, Message: "news_ids must list every article in the collection exactly once", Details: map[string]string{"news_ids": "must list every article in the collection exactly once"}})
+		}
+		seen[id] = true
+	}
+	if len(seen) != len(members) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeValidationFailed, Message: "news_ids must list every article in the collection exactly once", Details: map[string]string{"news_ids": "must list every article in the collection exactly once"}})
+	}
+
+	for i, id := range body.NewsIDs {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE news_collections SET position = $1
+			WHERE collection_id = $2 AND news_id = $3
+		`, i+1, collectionID, id)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to reorder collection"})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to reorder collection"})
+	}
+
+	collection, err := getCollection(ctx, slug)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to fetch collection"})
+	}
+	return respondCollectionDetail(c, http.StatusOK, collection)
+}
@@ -0,0 +1,150 @@
+// collections_test.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func collectionContext(e *echo.Echo, method, body string, rec *httptest.ResponseRecorder, names []string, values ...string) echo.Context {
+	req := httptest.NewRequest(method, "/", bytes.NewBufferString(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c := e.NewContext(req, rec)
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+	return c
+}
+
+func itemIDs(detail CollectionDetail) []int {
+	ids := []int{}
+	for _, item := range detail.Items {
+		ids = append(ids, item.News.ID)
+	}
+	return ids
+}
+
+// Test collection CRUD, membership changes and prev/next navigation
+func TestCollectionLifecycle(t *testing.T) {
+	requirePostgres(t)
+	e := setupEcho()
+	slugParam := []string{"slug"}
+
+	var topicID int
+	err := db.QueryRow(`INSERT INTO topics (name, description) VALUES ('Collection Topic', '') RETURNING id`).Scan(&topicID)
+	assert.NoError(t, err)
+	defer db.Exec("DELETE FROM topics WHERE id = $1", topicID)
+
+	var parts [3]int
+	for i := range parts {
+		err := db.QueryRow(`INSERT INTO news (title, content, topic_id) VALUES ($1, 'Body', $2) RETURNING id`,
+			"Part "+strconv.Itoa(i+1), topicID).Scan(&parts[i])
+		assert.NoError(t, err)
+	}
+	defer db.Exec("DELETE FROM news WHERE topic_id = $1", topicID)
+
+	// 1. Create with a slug derived from the title
+	rec := httptest.NewRecorder()
+	assert.NoError(t, createCollection(collectionContext(e, http.MethodPost, `{"title":"The Big Investigation"}`, rec, nil)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var collection Collection
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &collection))
+	assert.Equal(t, "the-big-investigation", collection.Slug)
+	defer db.Exec("DELETE FROM collections WHERE id = $1", collection.ID)
+
+	// Duplicate slugs conflict
+	rec = httptest.NewRecorder()
+	assert.NoError(t, createCollection(collectionContext(e, http.MethodPost, `{"title":"The Big Investigation"}`, rec, nil)))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	// 2. Append parts 1 and 3, then insert part 2 between them
+	for _, body := range []string{
+		`{"news_id":` + strconv.Itoa(parts[0]) + `}`,
+		`{"news_id":` + strconv.Itoa(parts[2]) + `}`,
+		`{"news_id":` + strconv.Itoa(parts[1]) + `,"position":2}`,
+	} {
+		rec = httptest.NewRecorder()
+		assert.NoError(t, addCollectionNews(collectionContext(e, http.MethodPost, body, rec, slugParam, collection.Slug)))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// Adding the same article twice conflicts
+	rec = httptest.NewRecorder()
+	assert.NoError(t, addCollectionNews(collectionContext(e, http.MethodPost, `{"news_id":`+strconv.Itoa(parts[0])+`}`, rec, slugParam, collection.Slug)))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	// 3. Fetch in order with prev/next stubs
+	rec = httptest.NewRecorder()
+	assert.NoError(t, getCollectionBySlug(collectionContext(e, http.MethodGet, "", rec, slugParam, collection.Slug)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var detail CollectionDetail
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &detail))
+	assert.Equal(t, 3, detail.Total)
+	assert.Equal(t, parts[:], itemIDs(detail))
+	assert.Nil(t, detail.Items[0].Prev)
+	assert.Equal(t, parts[1], detail.Items[0].Next.ID)
+	assert.Equal(t, 2, detail.Items[1].Position)
+	assert.Equal(t, parts[0], detail.Items[1].Prev.ID)
+	assert.Equal(t, parts[2], detail.Items[1].Next.ID)
+	assert.Nil(t, detail.Items[2].Next)
+
+	// 4. Reorder; the list must match the members exactly
+	rec = httptest.NewRecorder()
+	bad := `{"news_ids":[` + strconv.Itoa(parts[0]) + `,` + strconv.Itoa(parts[0]) + `]}`
+	assert.NoError(t, reorderCollectionNews(collectionContext(e, http.MethodPut, bad, rec, slugParam, collection.Slug)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	order := `{"news_ids":[` + strconv.Itoa(parts[2]) + `,` + strconv.Itoa(parts[0]) + `,` + strconv.Itoa(parts[1]) + `]}`
+	assert.NoError(t, reorderCollectionNews(collectionContext(e, http.MethodPut, order, rec, slugParam, collection.Slug)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &detail))
+	assert.Equal(t, []int{parts[2], parts[0], parts[1]}, itemIDs(detail))
+
+	// 5. Remove one member explicitly and delete another article outright
+	rec = httptest.NewRecorder()
+	assert.NoError(t, removeCollectionNews(collectionContext(e, http.MethodDelete, "", rec, []string{"slug", "news_id"}, collection.Slug, strconv.Itoa(parts[2]))))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	assert.NoError(t, deleteNews(collectionContext(e, http.MethodDelete, "", rec, []string{"id"}, strconv.Itoa(parts[0]))))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	assert.NoError(t, getCollectionBySlug(collectionContext(e, http.MethodGet, "", rec, slugParam, collection.Slug)))
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &detail))
+	assert.Equal(t, []int{parts[1]}, itemIDs(detail))
+	assert.Equal(t, 1, detail.Items[0].Position)
+
+	var position int
+	db.QueryRow("SELECT position FROM news_collections WHERE news_id = $1", parts[1]).Scan(&position)
+	assert.Equal(t, 1, position)
+
+	// 6. Delete the collection
+	rec = httptest.NewRecorder()
+	assert.NoError(t, deleteCollection(collectionContext(e, http.MethodDelete, "", rec, slugParam, collection.Slug)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	assert.NoError(t, getCollectionBySlug(collectionContext(e, http.MethodGet, "", rec, slugParam, collection.Slug)))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// Malformed news ids are rejected before the database sees them
+func TestRemoveCollectionNewsInvalidID(t *testing.T) {
+	e := setupEcho()
+	for _, id := range []string{"abc", "-1", "99999999999999999999"} {
+		rec := httptest.NewRecorder()
+		assert.NoError(t, removeCollectionNews(collectionContext(e, http.MethodDelete, "", rec, []string{"slug", "news_id"}, "series", id)))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, id)
+		assert.Contains(t, rec.Body.String(), `"code":"INVALID_PARAMETER"`, id)
+	}
+}
@@ -0,0 +1,200 @@
+// config.go
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Config is the settings the process resolved from its environment, with
+// defaults applied. Fields carrying credentials are tagged redact:"secret"
+// (dropped entirely) or redact:"url" (password and sensitive query
+// parameters masked); marshal them only through redactConfig.
+type Config struct {
+	Port                     string `json:"port"`
+	LogLevel                 string `json:"log_level"`
+	Storage                  string `json:"storage"`
+	DatabaseURL              string `json:"database_url" redact:"url"`
+	MaxContentBytes          int    `json:"max_content_bytes"`
+	MaxBodyBytes             int    `json:"max_body_bytes"`
+	QueryTimeout             string `json:"query_timeout"`
+	ShutdownTimeout          string `json:"shutdown_timeout"`
+	ReadinessGrace           string `json:"readiness_grace"`
+	AutoMigrate              bool   `json:"auto_migrate"`
+	MigrationLockTimeout     string `json:"migration_lock_timeout"`
+	DBMaxOpenConns           int    `json:"db_max_open_conns"`
+	DBMaxIdleConns           int    `json:"db_max_idle_conns"`
+	DBConnMaxLifetime        string `json:"db_conn_max_lifetime"`
+	DBConnMaxIdleTime        string `json:"db_conn_max_idle_time"`
+	InlineTopicCreation      bool   `json:"inline_topic_creation"`
+	ArchiveAfter             string `json:"archive_after,omitempty"`
+	ArchiveInterval          string `json:"archive_interval,omitempty"`
+	ArchiveBatchSize         int    `json:"archive_batch_size,omitempty"`
+	ModerationWebhookURL     string `json:"moderation_webhook_url,omitempty" redact:"url"`
+	ModerationWebhookTimeout string `json:"moderation_webhook_timeout,omitempty"`
+	ModerationFailOpen       bool   `json:"moderation_fail_open"`
+	DebugPprof               bool   `json:"debug_pprof"`
+	ServerTiming             bool   `json:"server_timing"`
+	Auth                     bool   `json:"auth"`
+	JWTTTL                   string `json:"jwt_ttl,omitempty"`
+	CORSAllowedOrigins       string `json:"cors_allowed_origins"`
+}
+
+const redactedValue = "REDACTED"
+
+// Words that mark a field or query parameter as holding a credential
+var sensitiveWords = []string{"secret", "password", "passwd", "token", "apikey", "api_key"}
+
+func sensitiveName(name string) bool {
+	name = strings.ToLower(name)
+	for _, w := range sensitiveWords {
+		if strings.Contains(name, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvedConfig gathers the current settings. The loaders it calls have
+// already validated the environment at startup.
+func resolvedConfig() Config {
+	cfg := Config{
+		Port:                 listenPort(),
+		LogLevel:             logLevel(),
+		Storage:              "postgres",
+		DatabaseURL:          databaseURL(),
+		MaxContentBytes:      maxContentBytes,
+		MaxBodyBytes:         maxBodyBytes(),
+		QueryTimeout:         "none",
+		ShutdownTimeout:      loadShutdownTimeout().String(),
+		ReadinessGrace:       loadReadinessGrace().String(),
+		AutoMigrate:          autoMigrate(),
+		MigrationLockTimeout: migrationLockTimeout().String(),
+		InlineTopicCreation:  inlineTopicCreation,
+		DebugPprof:           pprofSecret != "",
+		ServerTiming:         timingSecret != "",
+		Auth:                 jwtSecret != nil,
+		CORSAllowedOrigins:   corsOriginsSetting(),
+	}
+	if cfg.Auth {
+		cfg.JWTTTL = tokenTTL.String()
+	}
+	if memoryStorage() {
+		cfg.Storage = "memory"
+	} else if d, ok := findDialect(cfg.DatabaseURL); ok {
+		cfg.Storage = d.name
+	}
+	if queryTimeout > 0 {
+		cfg.QueryTimeout = queryTimeout.String()
+	}
+	if p, err := loadPoolSettings(os.Getenv); err == nil {
+		cfg.DBMaxOpenConns = p.MaxOpenConns
+		cfg.DBMaxIdleConns = p.MaxIdleConns
+		cfg.DBConnMaxLifetime = p.ConnMaxLifetime.String()
+		cfg.DBConnMaxIdleTime = p.ConnMaxIdleTime.String()
+	}
+	if s, ok := loadArchiveSettings(); ok {
+		cfg.ArchiveAfter = s.After.String()
+		cfg.ArchiveInterval = s.Interval.String()
+		cfg.ArchiveBatchSize = s.BatchSize
+	}
+	if w, ok := loadModerationWebhook(); ok {
+		cfg.ModerationWebhookURL = w.URL
+		cfg.ModerationWebhookTimeout = w.Timeout.String()
+		cfg.ModerationFailOpen = w.FailOpen
+	}
+	return cfg
+}
+
+// redactConfig flattens a struct into its JSON field names with secrets
+// masked. A field whose name looks like a credential is masked even when
+// it carries no redact tag, so a new setting cannot leak by omission.
+func redactConfig(v interface{}) map[string]interface{} {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	rt := rv.Type()
+	out := make(map[string]interface{}, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		value := rv.Field(i)
+		if strings.Contains(opts, "omitempty") && value.IsZero() {
+			continue
+		}
+
+		switch tag := f.Tag.Get("redact"); {
+		case tag == "url" && value.Kind() == reflect.String:
+			out[name] = redactURL(value.String())
+		case tag != "" || sensitiveName(f.Name) || sensitiveName(name):
+			out[name] = redactedValue
+		default:
+			out[name] = value.Interface()
+		}
+	}
+	return out
+}
+
+// Matches password=... pairs in a libpq key/value connection string
+var dsnPassword = regexp.MustCompile(`(?i)(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// redactURL masks the password and any credential-like query parameters
+// of a URL, or of a key/value connection string. Values that cannot be
+// parsed are masked entirely.
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	if !strings.Contains(raw, "://") {
+		return dsnPassword.ReplaceAllString(raw, "${1}"+redactedValue)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redactedValue
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redactedValue)
+	}
+	q := u.Query()
+	masked := false
+	for k := range q {
+		if sensitiveName(k) {
+			q.Set(k, redactedValue)
+			masked = true
+		}
+	}
+	if masked {
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// logConfig writes the resolved configuration as one JSON log line
+func logConfig() {
+	b, err := json.Marshal(redactConfig(resolvedConfig()))
+	if err != nil {
+		slog.Error("Error encoding configuration", "error", err)
+		return
+	}
+	slog.Info("Resolved configuration", "config", json.RawMessage(b))
+}
+
+// getConfig serves the same redacted view logged at startup
+func getConfig(c echo.Context) error {
+	return c.JSON(http.StatusOK, redactConfig(resolvedConfig()))
+}
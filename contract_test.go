@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Run `go test -run TestContract -update-golden` after a deliberate change
+// to a response shape, and review the diff of testdata/golden.
+var updateGolden = flag.Bool("update-golden", false, "rewrite the golden files under testdata/golden")
+
+// The contract fixture uses ids far above anything the sequences hand out,
+// so the rows, and the responses built from them, are the same every run
+const contractFixture = `
+	INSERT INTO topics (id, uuid, name, description, created_at, updated_at) VALUES
+		(910001, '00000000-0000-4000-8000-000000910001', 'Contract fixture', 'Seeded for the golden-file tests', '2024-05-01 09:00:00', '2024-05-01 09:00:00');
+	INSERT INTO news (id, uuid, title, content, topic_id, created_at, updated_at) VALUES
+		(910001, '00000000-0000-4000-8000-000000920001', 'First article', 'First body', 910001, '2024-05-01 10:00:00', '2024-05-01 10:00:00'),
+		(910002, '00000000-0000-4000-8000-000000920002', 'Second article', 'Second body', 910001, '2024-05-01 11:00:00', '2024-05-02 08:30:00.5'),
+		(910003, '00000000-0000-4000-8000-000000920003', 'Third article', 'Third body', 910001, '2024-05-01 12:00:00', '2024-05-01 12:00:00');
+	INSERT INTO collections (id, title, slug, description, created_at, updated_at) VALUES
+		(910001, 'Contract series', 'contract-fixture', 'Seeded for the golden-file tests', '2024-05-03 00:00:00', '2024-05-03 00:00:00');
+	INSERT INTO news_collections (collection_id, news_id, position) VALUES
+		(910001, 910002, 1),
+		(910001, 910001, 2);
+`
+
+const contractCleanup = `
+	DELETE FROM collections WHERE id = 910001;
+	DELETE FROM news WHERE id BETWEEN 910001 AND 910003;
+	DELETE FROM topics WHERE id = 910001;
+`
+
+// TestContract checks the canonical responses of the public endpoints
+// byte for byte, field order and number formatting included, against
+// testdata/golden. Bodies are compared after json.Indent, which keeps
+// every token as served.
+func TestContract(t *testing.T) {
+	requirePostgres(t)
+	if _, err := db.Exec(contractCleanup + contractFixture); err != nil {
+		t.Fatalf("seeding contract fixture: %v", err)
+	}
+	defer db.Exec(contractCleanup)
+
+	cases := []struct {
+		golden string
+		method string
+		path   string
+		body   string
+		prefer string
+		status int
+	}{
+		{"news", http.MethodGet, "/api/news/910001", "", "", http.StatusOK},
+		{"news_with_topic", http.MethodGet, "/api/news/910002?include=topic", "", "", http.StatusOK},
+		{"news_list_page", http.MethodGet, "/api/news/topic/910001?limit=2", "", "", http.StatusOK},
+		{"news_list_envelope", http.MethodGet, "/api/news/topic/910001?limit=2", "", "return=envelope", http.StatusOK},
+		{"topic", http.MethodGet, "/api/topics/910001", "", "", http.StatusOK},
+		{"collection", http.MethodGet, "/api/collections/contract-fixture", "", "", http.StatusOK},
+		{"error_not_found", http.MethodGet, "/api/news/910999", "", "", http.StatusNotFound},
+		{"error_invalid_parameter", http.MethodGet, "/api/news/abc", "", "", http.StatusBadRequest},
+		{"error_validation", http.MethodPost, "/api/topics", `{"description":"No name"}`, "", http.StatusBadRequest},
+		{"error_method_not_allowed", http.MethodPost, "/health", "", "", http.StatusMethodNotAllowed},
+	}
+
+	e := newRouter()
+	for _, tc := range cases {
+		t.Run(tc.golden, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if tc.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tc.prefer != "" {
+				req.Header.Set("Prefer", tc.prefer)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tc.status, rec.Code, rec.Body.String())
+
+			var got bytes.Buffer
+			if !assert.NoError(t, json.Indent(&got, rec.Body.Bytes(), "", "  ")) {
+				return
+			}
+			got.WriteByte('\n')
+
+			path := filepath.Join("testdata", "golden", tc.golden+".json")
+			if *updateGolden {
+				assert.NoError(t, os.WriteFile(path, got.Bytes(), 0o644))
+				return
+			}
+			want, err := os.ReadFile(path)
+			if !assert.NoError(t, err, "missing golden file; run with -update-golden") {
+				return
+			}
+			assert.Equal(t, string(want), got.String(), "response differs from %s; rerun with -update-golden if the change is deliberate", path)
+		})
+	}
+}
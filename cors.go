@@ -0,0 +1,119 @@
+// cors.go
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// corsMaxAge is how long browsers may cache a preflight answer
+const corsMaxAge = 10 * time.Minute
+
+// corsOrigin is one CORS_ALLOWED_ORIGINS entry, e.g. https://example.com
+// or https://*.example.com. A wildcard matches subdomains at any depth but
+// not the domain itself; scheme and port must match exactly.
+type corsOrigin struct {
+	scheme   string
+	host     string // lower case with any port; after "*." for wildcards
+	wildcard bool
+}
+
+// corsOrigins are the origins browsers may call the API from. Empty means
+// same-origin only: no Access-Control-Allow-Origin is ever sent.
+var corsOrigins []corsOrigin
+
+func parseCORSOrigin(raw string) (corsOrigin, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return corsOrigin{}, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return corsOrigin{}, errors.New("scheme must be http or https")
+	}
+	if u.Host == "" || u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return corsOrigin{}, errors.New("must be scheme://host[:port] with nothing after the host")
+	}
+	o := corsOrigin{scheme: u.Scheme, host: strings.ToLower(u.Host)}
+	if rest, ok := strings.CutPrefix(o.host, "*."); ok {
+		o.host, o.wildcard = rest, true
+	}
+	if o.host == "" || strings.Contains(o.host, "*") || strings.HasPrefix(o.host, ".") || strings.HasPrefix(o.host, ":") {
+		return corsOrigin{}, errors.New("a wildcard may only be the first label, as in https://*.example.com")
+	}
+	return o, nil
+}
+
+func (o corsOrigin) matches(scheme, host string) bool {
+	if scheme != o.scheme {
+		return false
+	}
+	if o.wildcard {
+		return strings.HasSuffix(host, "."+o.host)
+	}
+	return host == o.host
+}
+
+// loadCORSSettings reads CORS_ALLOWED_ORIGINS, a comma separated list of
+// origins
+func loadCORSSettings() {
+	corsOrigins = nil
+	for _, raw := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		o, err := parseCORSOrigin(raw)
+		if err != nil {
+			fatalf("Invalid CORS_ALLOWED_ORIGINS entry %q: %v", raw, err)
+		}
+		corsOrigins = append(corsOrigins, o)
+	}
+}
+
+// allowedOrigin reports whether a browser at origin may call the API
+func allowedOrigin(origin string) (bool, error) {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" || (u.Path != "" && u.Path != "/") {
+		return false, nil
+	}
+	host := strings.ToLower(u.Host)
+	for _, o := range corsOrigins {
+		if o.matches(u.Scheme, host) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// corsMiddleware answers preflights and marks responses readable by the
+// allowed origins. Others get no CORS headers, so browsers keep them to
+// same-origin.
+func corsMiddleware() echo.MiddlewareFunc {
+	return middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOriginFunc: allowedOrigin,
+		AllowMethods:    []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete},
+		AllowHeaders:    []string{echo.HeaderContentType, echo.HeaderAuthorization, echo.HeaderXRequestID, "Prefer"},
+		ExposeHeaders:   []string{echo.HeaderXRequestID, "Preference-Applied", "X-Total-Count", "X-Next-Cursor", "Server-Timing"},
+		MaxAge:          int(corsMaxAge / time.Second),
+	})
+}
+
+// corsOriginsSetting is CORS_ALLOWED_ORIGINS as resolved, for the config
+func corsOriginsSetting() string {
+	origins := make([]string, len(corsOrigins))
+	for i, o := range corsOrigins {
+		host := o.host
+		if o.wildcard {
+			host = "*." + host
+		}
+		origins[i] = o.scheme + "://" + host
+	}
+	return strings.Join(origins, ",")
+}
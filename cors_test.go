@@ -0,0 +1,106 @@
+// cors_test.go
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func useCORSOrigins(t *testing.T, value string) {
+	saved := corsOrigins
+	t.Setenv("CORS_ALLOWED_ORIGINS", value)
+	loadCORSSettings()
+	t.Cleanup(func() { corsOrigins = saved })
+}
+
+func TestParseCORSOrigin(t *testing.T) {
+	o, err := parseCORSOrigin("https://*.Example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, corsOrigin{scheme: "https", host: "example.com", wildcard: true}, o)
+	o, err = parseCORSOrigin("http://localhost:3000/")
+	assert.NoError(t, err)
+	assert.Equal(t, corsOrigin{scheme: "http", host: "localhost:3000"}, o)
+
+	for _, bad := range []string{"*", "example.com", "ftp://example.com", "https://example.com/app", "https://user@example.com", "https://a.*.example.com", "https://*example.com", "https://*.", "https://example.com?x=1"} {
+		_, err := parseCORSOrigin(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestAllowedOrigin(t *testing.T) {
+	useCORSOrigins(t, "https://news.example.com, https://*.partner.org, http://localhost:3000")
+
+	for origin, allowed := range map[string]bool{
+		"https://news.example.com":     true,
+		"https://NEWS.example.com":     true,
+		"https://app.partner.org":      true,
+		"https://a.b.partner.org":      true,
+		"http://localhost:3000":        true,
+		"http://news.example.com":      false,
+		"https://partner.org":          false,
+		"https://evilpartner.org":      false,
+		"https://app.partner.org.evil": false,
+		"https://app.partner.org:8443": false,
+		"http://localhost:3001":        false,
+		"null":                         false,
+		"":                             false,
+	} {
+		got, err := allowedOrigin(origin)
+		assert.NoError(t, err)
+		assert.Equal(t, allowed, got, origin)
+	}
+	assert.Equal(t, "https://news.example.com,https://*.partner.org,http://localhost:3000", corsOriginsSetting())
+}
+
+func corsRequest(e http.Handler, method, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/topics", nil)
+	req.Header.Set(echo.HeaderOrigin, origin)
+	if method == http.MethodOptions {
+		req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodPost)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSAllowList(t *testing.T) {
+	useStore(t, newMemStore())
+	useCORSOrigins(t, "https://*.example.com")
+	e := newRouter()
+
+	rec := corsRequest(e, http.MethodOptions, "https://app.example.com")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://app.example.com", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	assert.Contains(t, rec.Header().Get(echo.HeaderAccessControlAllowMethods), http.MethodPatch)
+	assert.Contains(t, rec.Header().Get(echo.HeaderAccessControlAllowHeaders), echo.HeaderAuthorization)
+	assert.Equal(t, "600", rec.Header().Get(echo.HeaderAccessControlMaxAge))
+
+	rec = corsRequest(e, http.MethodGet, "https://app.example.com")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "https://app.example.com", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	assert.Contains(t, rec.Header().Get(echo.HeaderAccessControlExposeHeaders), echo.HeaderXRequestID)
+
+	// Other origins still get an answer, but none a browser will hand over
+	rec = corsRequest(e, http.MethodGet, "https://example.org")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	rec = corsRequest(e, http.MethodOptions, "https://example.org")
+	assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowMethods))
+}
+
+// Without CORS_ALLOWED_ORIGINS no origin is allowed, rather than every one
+func TestCORSDefaultsToSameOrigin(t *testing.T) {
+	useStore(t, newMemStore())
+	useCORSOrigins(t, "")
+	e := newRouter()
+
+	for _, method := range []string{http.MethodGet, http.MethodOptions} {
+		rec := corsRequest(e, method, "https://anywhere.example.com")
+		assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowOrigin), method)
+	}
+}
@@ -0,0 +1,59 @@
+// daterange.go
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DateRange bounds created_at to [From, Until). Either end may be zero,
+// meaning unbounded.
+type DateRange struct {
+	From  time.Time
+	Until time.Time
+}
+
+// parseDateRange reads ?from= and ?to=, each RFC3339 or a plain
+// YYYY-MM-DD date. A plain to date includes that whole day; an RFC3339 to
+// includes that instant. The error message is safe to return to clients.
+func parseDateRange(c echo.Context) (DateRange, error) {
+	var r DateRange
+
+	if v := c.QueryParam("from"); v != "" {
+		t, _, err := parseDateParam(v)
+		if err != nil {
+			return r, errors.New("From must be an RFC3339 timestamp or a YYYY-MM-DD date")
+		}
+		r.From = t
+	}
+
+	if v := c.QueryParam("to"); v != "" {
+		t, dateOnly, err := parseDateParam(v)
+		if err != nil {
+			return r, errors.New("To must be an RFC3339 timestamp or a YYYY-MM-DD date")
+		}
+		// Stored timestamps have microsecond precision
+		if dateOnly {
+			r.Until = t.AddDate(0, 0, 1)
+		} else {
+			r.Until = t.Add(time.Microsecond)
+		}
+	}
+
+	if !r.From.IsZero() && !r.Until.IsZero() && !r.From.Before(r.Until) {
+		return r, errors.New("From must be before to")
+	}
+	return r, nil
+}
+
+// parseDateParam parses v as RFC3339 or a plain date, returning UTC and
+// whether it was a plain date
+func parseDateParam(v string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t.UTC(), false, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	return t, true, err
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDateRange(t *testing.T) {
+	e := setupEcho()
+	jan1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		from, to string
+		want     DateRange
+		ok       bool
+	}{
+		{"", "", DateRange{}, true},
+		{"2024-01-01", "", DateRange{From: jan1}, true},
+		{"", "2024-01-31", DateRange{Until: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)}, true},
+		{"2024-01-01", "2024-01-01", DateRange{From: jan1, Until: jan1.AddDate(0, 0, 1)}, true},
+		{"2024-01-01T09:00:00+02:00", "", DateRange{From: jan1.Add(7 * time.Hour)}, true},
+		{"", "2024-01-01T12:00:00Z", DateRange{Until: jan1.Add(12*time.Hour + time.Microsecond)}, true},
+		{"2024-01-31", "2024-01-01", DateRange{}, false},
+		{"2024-13-01", "", DateRange{}, false},
+		{"", "yesterday", DateRange{}, false},
+		{"01/02/2024", "", DateRange{}, false},
+	}
+
+	for _, tc := range cases {
+		q := url.Values{}
+		if tc.from != "" {
+			q.Set("from", tc.from)
+		}
+		if tc.to != "" {
+			q.Set("to", tc.to)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/api/news?"+q.Encode(), nil)
+		r, err := parseDateRange(e.NewContext(req, httptest.NewRecorder()))
+		if tc.ok {
+			assert.NoError(t, err, "%s..%s", tc.from, tc.to)
+			assert.True(t, tc.want.From.Equal(r.From), "from %s: got %s", tc.from, r.From)
+			assert.True(t, tc.want.Until.Equal(r.Until), "to %s: got %s", tc.to, r.Until)
+		} else {
+			assert.Error(t, err, "%s..%s", tc.from, tc.to)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// envelope.go
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ListEnvelope is the {data, meta} shape returned to clients that send
+// Prefer: return=envelope. Everyone else gets the bare array.
+type ListEnvelope[T any] struct {
+	Data []T      `json:"data"`
+	Meta ListMeta `json:"meta"`
+}
+
+type ListMeta struct {
+	Count      int    `json:"count"`
+	Total      *int64 `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// MinimalItem is a list entry under Prefer: return=minimal: just enough
+// for batch jobs to plan work. Lists fill in whichever identifier the
+// resource exposes.
+type MinimalItem struct {
+	ID        int       `json:"id"`
+	UUID      string    `json:"uuid,omitempty"`
+	Slug      string    `json:"slug,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// returnPreference is the lowercased return= value of the request's Prefer
+// header, or "" if none was sent. Preferences are comma separated and may
+// carry parameters after a semicolon.
+func returnPreference(r *http.Request) string {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(header, ",") {
+			pref, _, _ = strings.Cut(pref, ";")
+			pref = strings.ToLower(strings.ReplaceAll(pref, " ", ""))
+			if v, ok := strings.CutPrefix(pref, "return="); ok {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// prefersEnvelope reports whether the request asks for return=envelope
+func prefersEnvelope(r *http.Request) bool {
+	return returnPreference(r) == "envelope"
+}
+
+// applyMinimal reports whether the request asks for return=minimal and,
+// if so, acknowledges it. Only lists that honour it call this.
+func applyMinimal(c echo.Context) bool {
+	if returnPreference(c.Request()) != "minimal" {
+		return false
+	}
+	c.Response().Header().Set("Preference-Applied", "return=minimal")
+	return true
+}
+
+// listMinimal writes the MinimalItem list selected by query, whose columns
+// are id, uuid, slug and updated_at. Resources without one of the
+// identifiers select an empty string for it.
+func listMinimal(c echo.Context, query, failMessage string) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: failMessage})
+	}
+	defer rows.Close()
+
+	var items []MinimalItem
+	for rows.Next() {
+		var item MinimalItem
+		if err := rows.Scan(&item.ID, &item.UUID, &item.Slug, &item.UpdatedAt); err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: failMessage})
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: failMessage})
+	}
+
+	return respondList(c, items)
+}
+
+// respondList writes a list response in whichever shape the client asked
+// for. All list handlers go through here.
+func respondList[T any](c echo.Context, items []T) error {
+	return respondListMeta(c, items, ListMeta{})
+}
+
+// respondListMeta is respondList for paginated lists. Bare-array responses
+// carry the metadata in headers instead; Count is always filled in here.
+func respondListMeta[T any](c echo.Context, items []T, meta ListMeta) error {
+	meta.Count = len(items)
+	header := c.Response().Header()
+	header.Add("Vary", "Prefer")
+	if meta.Total != nil {
+		header.Set("X-Total-Count", strconv.FormatInt(*meta.Total, 10))
+	}
+	if meta.NextCursor != "" {
+		header.Set("X-Next-Cursor", meta.NextCursor)
+	}
+
+	// Empty lists are [] in both shapes, never null
+	if items == nil {
+		items = []T{}
+	}
+	if !prefersEnvelope(c.Request()) {
+		return c.JSON(http.StatusOK, items)
+	}
+
+	header.Set("Preference-Applied", "return=envelope")
+	return c.JSON(http.StatusOK, ListEnvelope[T]{Data: items, Meta: meta})
+}
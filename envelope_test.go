@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefersEnvelope(t *testing.T) {
+	cases := []struct {
+		headers []string
+		want    bool
+	}{
+		{nil, false},
+		{[]string{"return=envelope"}, true},
+		{[]string{"Return=Envelope"}, true},
+		{[]string{"respond-async, return=envelope; foo=bar"}, true},
+		{[]string{"respond-async", "return = envelope"}, true},
+		{[]string{"return=representation"}, false},
+		{[]string{"return=envelopes"}, false},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, h := range tc.headers {
+			req.Header.Add("Prefer", h)
+		}
+		assert.Equal(t, tc.want, prefersEnvelope(req), "%q", tc.headers)
+	}
+}
+
+func TestReturnPreference(t *testing.T) {
+	cases := map[string]string{
+		"":                               "",
+		"return=minimal":                 "minimal",
+		"Return=Minimal; foo=bar":        "minimal",
+		"respond-async, return=envelope": "envelope",
+		"respond-async":                  "",
+	}
+	for header, want := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set("Prefer", header)
+		}
+		assert.Equal(t, want, returnPreference(req), "%q", header)
+	}
+}
+
+func TestRespondListShapes(t *testing.T) {
+	e := echo.New()
+	topics := []Topic{{ID: 1, Name: "Go"}}
+
+	list := func(prefer string, items []Topic) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if prefer != "" {
+			req.Header.Set("Prefer", prefer)
+		}
+		rec := httptest.NewRecorder()
+		assert.NoError(t, respondList(e.NewContext(req, rec), items))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Prefer", rec.Header().Get("Vary"))
+		return rec
+	}
+
+	// Bare arrays stay exactly as before
+	rec := list("", topics)
+	assert.JSONEq(t, `[{"id":1,"uuid":"","name":"Go","description":"","tagline":"","long_description":"","meta_title":"","meta_description":"","header_image_url":"","created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}]`, rec.Body.String())
+	assert.Empty(t, rec.Header().Get("Preference-Applied"))
+
+	rec = list("", nil)
+	assert.Equal(t, "[]", strings.TrimSpace(rec.Body.String()))
+
+	rec = list("return=envelope", topics)
+	assert.JSONEq(t, `{"data":[{"id":1,"uuid":"","name":"Go","description":"","tagline":"","long_description":"","meta_title":"","meta_description":"","header_image_url":"","created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}],"meta":{"count":1}}`, rec.Body.String())
+	assert.Equal(t, "return=envelope", rec.Header().Get("Preference-Applied"))
+
+	rec = list("return=envelope", nil)
+	assert.JSONEq(t, `{"data":[],"meta":{"count":0}}`, rec.Body.String())
+
+	// Paginated lists add the total and cursor to meta, or as headers
+	total := int64(7)
+	meta := ListMeta{Total: &total, NextCursor: "abc"}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	assert.NoError(t, respondListMeta(e.NewContext(req, rec), topics[:0], meta))
+	assert.JSONEq(t, `[]`, rec.Body.String())
+	assert.Equal(t, "7", rec.Header().Get("X-Total-Count"))
+	assert.Equal(t, "abc", rec.Header().Get("X-Next-Cursor"))
+
+	req.Header.Set("Prefer", "return=envelope")
+	rec = httptest.NewRecorder()
+	assert.NoError(t, respondListMeta(e.NewContext(req, rec), topics, meta))
+	assert.JSONEq(t, `{"data":[{"id":1,"uuid":"","name":"Go","description":"","tagline":"","long_description":"","meta_title":"","meta_description":"","header_image_url":"","created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}],"meta":{"count":1,"total":7,"next_cursor":"abc"}}`, rec.Body.String())
+}
+
+// Lists honouring return=minimal send identifiers only and say so
+func TestMinimalLists(t *testing.T) {
+	requirePostgres(t)
+	t.Parallel()
+	s := newTestServer(t)
+
+	var topic Topic
+	if !s.expect(s.do(http.MethodPost, "/api/topics", map[string]string{"name": uniqueName(t, "Minimal")}), http.StatusCreated, &topic) {
+		return
+	}
+	defer db.Exec("DELETE FROM topics WHERE id = $1", topic.ID)
+	var news News
+	rec := s.do(http.MethodPost, "/api/news", map[string]interface{}{"title": "Minimal", "content": "Body", "topic_id": topic.ID})
+	if !s.expect(rec, http.StatusCreated, &news) {
+		return
+	}
+	defer db.Exec("DELETE FROM news WHERE id = $1", news.ID)
+
+	get := func(path, prefer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if prefer != "" {
+			req.Header.Set("Prefer", prefer)
+		}
+		rec := httptest.NewRecorder()
+		s.e.ServeHTTP(rec, req)
+		return rec
+	}
+	topicPath := "/api/news/topic/" + strconv.Itoa(topic.ID)
+
+	for _, path := range []string{topicPath + "?include=topic", "/api/topics", "/api/collections"} {
+		rec := get(path, "return=minimal")
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+		assert.Equal(t, "return=minimal", rec.Header().Get("Preference-Applied"), path)
+		assert.Empty(t, rec.Header().Get("X-Total-Count"), path)
+
+		var items []map[string]interface{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &items), path)
+		for _, item := range items {
+			assert.Contains(t, item, "id", path)
+			assert.Contains(t, item, "updated_at", path)
+			assert.NotContains(t, item, "title", path)
+			assert.NotContains(t, item, "topic", path)
+		}
+	}
+
+	rec = get(topicPath, "return=minimal")
+	assert.JSONEq(t, `[{"id":`+strconv.Itoa(news.ID)+`,"uuid":"`+news.UUID+`","updated_at":"`+news.UpdatedAt.Format(time.RFC3339Nano)+`"}]`, rec.Body.String())
+
+	// Without the preference nothing changes
+	rec = get(topicPath, "")
+	assert.Empty(t, rec.Header().Get("Preference-Applied"))
+	assert.Equal(t, "1", rec.Header().Get("X-Total-Count"))
+}
+
+// BenchmarkTopicListing compares the full and minimal listing of a
+// 10k-article topic; run with -bench TopicListing against a test database
+func BenchmarkTopicListing(b *testing.B) {
+	var topicID int
+	if err := db.QueryRow("INSERT INTO topics (name) VALUES ('Benchmark listing') RETURNING id").Scan(&topicID); err != nil {
+		b.Fatal(err)
+	}
+	defer db.Exec("DELETE FROM topics WHERE id = $1", topicID)
+	_, err := db.Exec(`
+		INSERT INTO news (title, content, topic_id, created_at, updated_at)
+		SELECT 'Article ' || i, repeat('Body text ', 200), $1, now() - i * interval '1 minute', now()
+		FROM generate_series(1, 10000) i
+	`, topicID)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Exec("DELETE FROM news WHERE topic_id = $1", topicID)
+
+	e := newRouter()
+	path := "/api/news/topic/" + strconv.Itoa(topicID) + "?limit=100"
+	for _, prefer := range []string{"", "return=minimal"} {
+		name := "full"
+		if prefer != "" {
+			name = "minimal"
+		}
+		b.Run(name, func(b *testing.B) {
+			var size int
+			for i := 0; i < b.N; i++ {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				if prefer != "" {
+					req.Header.Set("Prefer", prefer)
+				}
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+				size = rec.Body.Len()
+			}
+			b.ReportMetric(float64(size), "payload-bytes")
+		})
+	}
+}
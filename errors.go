@@ -0,0 +1,271 @@
+// errors.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+// Stable error codes for ErrorResponse.Code. Clients match on these, so
+// existing values must not change.
+const (
+	codeInvalidPayload         = "INVALID_PAYLOAD"
+	codeInvalidParameter       = "INVALID_PARAMETER"
+	codeValidationFailed       = "VALIDATION_FAILED"
+	codeInvalidReference       = "INVALID_REFERENCE"
+	codeDuplicate              = "DUPLICATE"
+	codeConflict               = "CONFLICT"
+	codeTimeout                = "TIMEOUT"
+	codeNewsNotFound           = "NEWS_NOT_FOUND"
+	codeTopicNotFound          = "TOPIC_NOT_FOUND"
+	codeCollectionNotFound     = "COLLECTION_NOT_FOUND"
+	codeNewsNotInCollection    = "NEWS_NOT_IN_COLLECTION"
+	codeModerationTermNotFound = "MODERATION_TERM_NOT_FOUND"
+	codeNewsArchived           = "NEWS_ARCHIVED"
+	codeTopicInUse             = "TOPIC_IN_USE"
+	codeContentTooLarge        = "CONTENT_TOO_LARGE"
+	codeModerationRejected     = "MODERATION_REJECTED"
+	codeNotImplemented         = "NOT_IMPLEMENTED"
+	codeSequenceBehind         = "SEQUENCE_BEHIND"
+	codeUnauthorized           = "UNAUTHORIZED"
+	codeForbidden              = "FORBIDDEN"
+	codeTokenExpired           = "TOKEN_EXPIRED"
+	codeTokenInvalid           = "TOKEN_INVALID"
+	codeInvalidCredentials     = "INVALID_CREDENTIALS"
+	codeInternal               = "INTERNAL_ERROR"
+)
+
+// notFoundCode is the code for a missing resource, e.g. NEWS_NOT_FOUND
+func notFoundCode(resource string) string {
+	return strings.ToUpper(strings.ReplaceAll(resource, " ", "_")) + "_NOT_FOUND"
+}
+
+// requiredFields returns Details naming each empty value in fields as
+// required, or nil if none are
+func requiredFields(fields map[string]string) map[string]string {
+	var details map[string]string
+	for name, value := range fields {
+		if value == "" {
+			if details == nil {
+				details = map[string]string{}
+			}
+			details[name] = "required"
+		}
+	}
+	return details
+}
+
+// Errors returned by storeError and the Store. Handlers pass them to
+// respondError rather than inspecting driver errors themselves.
+var (
+	ErrNotFound = errors.New("not found")
+	ErrConflict = errors.New("conflict")
+	ErrTimeout  = errors.New("timeout")
+	ErrArchived = errors.New("archived")
+	ErrInUse    = errors.New("in use")
+)
+
+// DuplicateError reports a unique constraint violation on Field
+type DuplicateError struct {
+	Field string
+}
+
+func (e *DuplicateError) Error() string {
+	return "duplicate " + e.Field
+}
+
+// ForeignKeyError reports a reference to a row that does not exist
+type ForeignKeyError struct {
+	Ref string
+}
+
+func (e *ForeignKeyError) Error() string {
+	return "missing " + e.Ref
+}
+
+// SequenceBehindError reports an insert that was given an id already in
+// use, because Table's id sequence is behind its rows after a restore or
+// an import that kept its ids
+type SequenceBehindError struct {
+	Table string
+}
+
+func (e *SequenceBehindError) Error() string {
+	return e.Table + " id sequence is behind"
+}
+
+// InvalidValueError reports a value the schema rejected: a missing
+// required column, a failed CHECK or an over-long string. Field is empty
+// when Postgres does not say which column it was.
+type InvalidValueError struct {
+	Field   string
+	Problem string
+}
+
+func (e *InvalidValueError) Error() string {
+	return "invalid " + e.Field + ": " + e.Problem
+}
+
+// constraintFields names what each constraint protects, for messages.
+// Unlisted constraints fall back to the constraint name.
+var constraintFields = map[string]string{
+	"topics_name_key":                     "name",
+	"topics_long_description_length":      "long_description",
+	"collections_slug_key":                "slug",
+	"moderation_terms_term_key":           "term",
+	"users_email_key":                     "email",
+	"users_role_check":                    "role",
+	"moderation_terms_action_check":       "action",
+	"news_collections_pkey":               "news",
+	"news_topic_id_fkey":                  "topic",
+	"news_archive_topic_id_fkey":          "topic",
+	"news_collections_collection_id_fkey": "collection",
+	"moderation_flags_news_id_fkey":       "news",
+}
+
+func constraintField(constraint string) string {
+	if field, ok := constraintFields[constraint]; ok {
+		return field
+	}
+	return constraint
+}
+
+// storeError translates a database/sql or Postgres error into one of the
+// typed errors above. Anything unrecognised is returned unchanged.
+func storeError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505": // unique_violation
+			if table, ok := serialTable(pqErr.Constraint); ok {
+				return &SequenceBehindError{Table: table}
+			}
+			return &DuplicateError{Field: constraintField(pqErr.Constraint)}
+		case "23503": // foreign_key_violation
+			return &ForeignKeyError{Ref: constraintField(pqErr.Constraint)}
+		case "23502": // not_null_violation
+			return &InvalidValueError{Field: pqErr.Column, Problem: "required"}
+		case "23514": // check_violation
+			return &InvalidValueError{Field: constraintField(pqErr.Constraint), Problem: "not allowed"}
+		case "22001": // string_data_right_truncation
+			return &InvalidValueError{Problem: "too long"}
+		case "40001", "40P01": // serialization_failure, deadlock_detected
+			return ErrConflict
+		case "57014": // query_canceled, as sent for an expired context
+			return ErrTimeout
+		}
+	}
+	return err
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation
+func isUniqueViolation(err error) bool {
+	var dup *DuplicateError
+	return errors.As(storeError(err), &dup)
+}
+
+// errorStatus maps a store error to its status and response body. resource
+// names the entity in messages; fallback is the message for anything
+// unexpected, which is reported as a 500.
+func errorStatus(err error, resource, fallback string) (int, ErrorResponse) {
+	err = storeError(err)
+
+	var dup *DuplicateError
+	var fk *ForeignKeyError
+	var inv *InvalidValueError
+	var seq *SequenceBehindError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound, ErrorResponse{Code: notFoundCode(resource), Message: resource + " not found"}
+	case errors.As(err, &dup):
+		return http.StatusConflict, ErrorResponse{
+			Code:    codeDuplicate,
+			Message: "A " + strings.ToLower(resource) + " with this " + dup.Field + " already exists",
+		}
+	case errors.As(err, &fk):
+		return http.StatusBadRequest, ErrorResponse{Code: codeInvalidReference, Message: "Referenced " + fk.Ref + " does not exist"}
+	case errors.As(err, &inv):
+		body := ErrorResponse{Code: codeValidationFailed, Message: "Invalid " + strings.ToLower(resource) + ": value " + inv.Problem}
+		if inv.Field != "" {
+			body.Message = "Invalid " + strings.ToLower(resource) + ": " + inv.Field + " " + inv.Problem
+			body.Details = map[string]string{inv.Field: inv.Problem}
+		}
+		return http.StatusBadRequest, body
+	case errors.As(err, &seq):
+		return http.StatusInternalServerError, ErrorResponse{
+			Code:    codeSequenceBehind,
+			Message: "The " + seq.Table + " id sequence is behind existing rows; an operator must run POST /api/admin/sequences/repair",
+		}
+	case errors.Is(err, ErrTimeout):
+		return http.StatusServiceUnavailable, ErrorResponse{Code: codeTimeout, Message: "The request took too long, please retry"}
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict, ErrorResponse{Code: codeConflict, Message: resource + " was modified concurrently, please retry"}
+	default:
+		return http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: fallback}
+	}
+}
+
+// respondError writes the error response for a failed store call
+func respondError(c echo.Context, err error, resource, fallback string) error {
+	status, body := errorStatus(err, resource, fallback)
+	return c.JSON(status, body)
+}
+
+// respondNewsError is respondError for article writes, which report a
+// missing topic against topic_id and refuse archived articles
+func respondNewsError(c echo.Context, err error, fallback string) error {
+	var fk *ForeignKeyError
+	switch {
+	case errors.As(err, &fk) && fk.Ref == "topic":
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidReference, Message: "Topic does not exist", Details: map[string]string{"topic_id": "does not exist"}})
+	case errors.Is(err, ErrArchived):
+		return c.JSON(http.StatusConflict, ErrorResponse{Code: codeNewsArchived, Message: "Archived news cannot be updated"})
+	}
+	return respondError(c, err, "News", fallback)
+}
+
+// httpErrorHandler renders errors that reach Echo rather than being
+// answered by a handler, such as unknown routes, wrong methods and
+// response encoding failures, in the ErrorResponse shape. Their code is
+// derived from the status, e.g. NOT_FOUND or METHOD_NOT_ALLOWED.
+func httpErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	status, message := http.StatusInternalServerError, "Internal server error"
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		status = he.Code
+		if m, ok := he.Message.(string); ok {
+			message = m
+		}
+	}
+
+	code := codeInternal
+	if status < http.StatusInternalServerError {
+		code = strings.ToUpper(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		err = c.JSON(status, ErrorResponse{Code: code, Message: message})
+	}
+	if err != nil {
+		requestLog(c.Request().Context()).Error("Error writing error response", "error", err)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreError(t *testing.T) {
+	assert.Equal(t, ErrNotFound, storeError(sql.ErrNoRows))
+	assert.Equal(t, ErrNotFound, storeError(fmt.Errorf("scan: %w", sql.ErrNoRows)))
+	assert.Equal(t, &DuplicateError{Field: "name"}, storeError(&pq.Error{Code: "23505", Constraint: "topics_name_key"}))
+	assert.Equal(t, &DuplicateError{Field: "other_key"}, storeError(&pq.Error{Code: "23505", Constraint: "other_key"}))
+	assert.Equal(t, &SequenceBehindError{Table: "news"}, storeError(&pq.Error{Code: "23505", Constraint: "news_pkey"}))
+	assert.Equal(t, &DuplicateError{Field: "news"}, storeError(&pq.Error{Code: "23505", Constraint: "news_collections_pkey"}))
+	assert.Equal(t, &ForeignKeyError{Ref: "topic"}, storeError(&pq.Error{Code: "23503", Constraint: "news_topic_id_fkey"}))
+	assert.Equal(t, &InvalidValueError{Field: "title", Problem: "required"}, storeError(&pq.Error{Code: "23502", Column: "title"}))
+	assert.Equal(t, &InvalidValueError{Field: "action", Problem: "not allowed"}, storeError(&pq.Error{Code: "23514", Constraint: "moderation_terms_action_check"}))
+	assert.Equal(t, &InvalidValueError{Problem: "too long"}, storeError(&pq.Error{Code: "22001"}))
+	assert.Equal(t, ErrConflict, storeError(&pq.Error{Code: "40001"}))
+	assert.Equal(t, ErrTimeout, storeError(&pq.Error{Code: "57014"}))
+	assert.Equal(t, ErrTimeout, storeError(fmt.Errorf("query: %w", context.DeadlineExceeded)))
+	assert.Equal(t, ErrConflict, storeError(&pq.Error{Code: "40P01"}))
+
+	other := &pq.Error{Code: "42P01"}
+	assert.Equal(t, other, storeError(other))
+	assert.Nil(t, storeError(nil))
+}
+
+// Every error storeError can produce must map to a deliberate status.
+// Adding a new error type means adding a row here.
+func TestErrorStatus(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		body   ErrorResponse
+	}{
+		{ErrNotFound, http.StatusNotFound, ErrorResponse{Code: "TOPIC_NOT_FOUND", Message: "Topic not found"}},
+		{sql.ErrNoRows, http.StatusNotFound, ErrorResponse{Code: "TOPIC_NOT_FOUND", Message: "Topic not found"}},
+		{&DuplicateError{Field: "name"}, http.StatusConflict, ErrorResponse{Code: "DUPLICATE", Message: "A topic with this name already exists"}},
+		{&pq.Error{Code: "23505", Constraint: "topics_name_key"}, http.StatusConflict, ErrorResponse{Code: "DUPLICATE", Message: "A topic with this name already exists"}},
+		{&ForeignKeyError{Ref: "topic"}, http.StatusBadRequest, ErrorResponse{Code: "INVALID_REFERENCE", Message: "Referenced topic does not exist"}},
+		{&pq.Error{Code: "23503", Constraint: "news_topic_id_fkey"}, http.StatusBadRequest, ErrorResponse{Code: "INVALID_REFERENCE", Message: "Referenced topic does not exist"}},
+		{&pq.Error{Code: "23502", Column: "name"}, http.StatusBadRequest, ErrorResponse{Code: "VALIDATION_FAILED", Message: "Invalid topic: name required", Details: map[string]string{"name": "required"}}},
+		{&pq.Error{Code: "22001"}, http.StatusBadRequest, ErrorResponse{Code: "VALIDATION_FAILED", Message: "Invalid topic: value too long"}},
+		{&pq.Error{Code: "23505", Constraint: "topics_pkey"}, http.StatusInternalServerError, ErrorResponse{Code: "SEQUENCE_BEHIND", Message: "The topics id sequence is behind existing rows; an operator must run POST /api/admin/sequences/repair"}},
+		{ErrTimeout, http.StatusServiceUnavailable, ErrorResponse{Code: "TIMEOUT", Message: "The request took too long, please retry"}},
+		{ErrConflict, http.StatusConflict, ErrorResponse{Code: "CONFLICT", Message: "Topic was modified concurrently, please retry"}},
+		{&pq.Error{Code: "40001"}, http.StatusConflict, ErrorResponse{Code: "CONFLICT", Message: "Topic was modified concurrently, please retry"}},
+		{fmt.Errorf("wrapped: %w", ErrConflict), http.StatusConflict, ErrorResponse{Code: "CONFLICT", Message: "Topic was modified concurrently, please retry"}},
+		{errors.New("connection reset"), http.StatusInternalServerError, ErrorResponse{Code: "INTERNAL_ERROR", Message: "Failed to update topic"}},
+		{&pq.Error{Code: "42P01"}, http.StatusInternalServerError, ErrorResponse{Code: "INTERNAL_ERROR", Message: "Failed to update topic"}},
+	}
+
+	for _, tc := range cases {
+		status, body := errorStatus(tc.err, "Topic", "Failed to update topic")
+		assert.Equal(t, tc.status, status, "%v", tc.err)
+		assert.Equal(t, tc.body, body, "%v", tc.err)
+	}
+}
+
+func TestNotFoundCode(t *testing.T) {
+	assert.Equal(t, codeNewsNotFound, notFoundCode("News"))
+	assert.Equal(t, codeTopicNotFound, notFoundCode("Topic"))
+	assert.Equal(t, codeCollectionNotFound, notFoundCode("Collection"))
+	assert.Equal(t, codeModerationTermNotFound, notFoundCode("Moderation term"))
+}
+
+func TestRequiredFields(t *testing.T) {
+	assert.Nil(t, requiredFields(map[string]string{"title": "T", "content": "C"}))
+	assert.Equal(t, map[string]string{"content": "required"}, requiredFields(map[string]string{"title": "T", "content": ""}))
+	assert.Equal(t, map[string]string{"title": "required", "content": "required"}, requiredFields(map[string]string{"title": "", "content": ""}))
+}
+
+// Errors answered by Echo itself use the same shape as handler errors
+func TestHTTPErrorHandler(t *testing.T) {
+	e := newRouter()
+
+	cases := []struct {
+		method, path string
+		status       int
+		code         string
+	}{
+		{http.MethodGet, "/api/nothing-here", http.StatusNotFound, "NOT_FOUND"},
+		{http.MethodPost, "/health", http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED"},
+		{http.MethodPatch, "/api/news", http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, tc.path)
+		var body ErrorResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body), tc.path)
+		assert.Equal(t, tc.code, body.Code, tc.path)
+		assert.NotEmpty(t, body.Message, tc.path)
+	}
+
+	// 405 says which methods the path does take
+	req := httptest.NewRequest(http.MethodPatch, "/api/news", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Contains(t, rec.Header().Get(echo.HeaderAllow), http.MethodGet)
+	assert.Contains(t, rec.Header().Get(echo.HeaderAllow), http.MethodPost)
+
+	// Failures without an HTTP status are internal errors
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	httpErrorHandler(errors.New("boom"), e.NewContext(req, rec))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.JSONEq(t, `{"code":"INTERNAL_ERROR","message":"Internal server error"}`, rec.Body.String())
+}
+
+func TestOversizedBody(t *testing.T) {
+	saved := maxContentBytes
+	defer func() { maxContentBytes = saved }()
+	maxContentBytes = 1024
+	e := newRouter()
+
+	body := `{"title":"Big","content":"` + strings.Repeat("x", maxBodyBytes()) + `","topic_id":1}`
+	req := httptest.NewRequest(http.MethodPost, "/api/news", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.JSONEq(t, `{"code":"REQUEST_ENTITY_TOO_LARGE","message":"Request Entity Too Large"}`, rec.Body.String())
+}
+
+// Constraint violations from the database come back as client errors
+func TestCreateTopicConstraintViolations(t *testing.T) {
+	requirePostgres(t)
+	t.Parallel()
+	s := newTestServer(t)
+	name := uniqueName(t, "Duplicate")
+
+	var topic Topic
+	if !s.expect(s.do(http.MethodPost, "/api/topics", map[string]string{"name": name}), http.StatusCreated, &topic) {
+		return
+	}
+	defer db.Exec("DELETE FROM topics WHERE id = $1", topic.ID)
+
+	var body ErrorResponse
+	s.expect(s.do(http.MethodPost, "/api/topics", map[string]string{"name": name}), http.StatusConflict, &body)
+	assert.Equal(t, "DUPLICATE", body.Code)
+	assert.Equal(t, "A topic with this name already exists", body.Message)
+
+	s.expect(s.do(http.MethodPost, "/api/topics", map[string]string{"name": strings.Repeat("x", 101)}), http.StatusBadRequest, &body)
+	assert.Equal(t, "VALIDATION_FAILED", body.Code)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// largeTableScan matches a sequential scan on any table that grows with
+// the number of articles
+var largeTableScan = regexp.MustCompile(`Seq Scan on (news|news_archive|news_collections|moderation_flags)\b`)
+
+// planQueries mirrors the queries the handlers run, with literals in place
+// of parameters
+var planQueries = map[string]string{
+	"getAllNews":           `SELECT id, uuid, title, content, topic_id, created_at, updated_at FROM news n ORDER BY n.created_at DESC, n.id DESC LIMIT 20 OFFSET 40`,
+	"getAllNewsByTitle":    `SELECT id FROM news n ORDER BY n.title ASC, n.id ASC LIMIT 21 OFFSET 0`,
+	"getAllNewsByUpdated":  `SELECT id FROM news n ORDER BY n.updated_at DESC, n.id DESC LIMIT 21 OFFSET 0`,
+	"getAllNewsArchived":   `SELECT n.id FROM (SELECT id, created_at FROM news UNION ALL SELECT id, created_at FROM news_archive) n ORDER BY n.created_at DESC, n.id DESC LIMIT 21 OFFSET 0`,
+	"searchNews":           `SELECT n.id, ts_rank(n.search_vector, query) AS rank FROM news n, plainto_tsquery('english', 'inflation') query WHERE n.search_vector @@ query ORDER BY rank DESC, n.id DESC LIMIT 20`,
+	"searchArchive":        `SELECT n.id FROM news_archive n, plainto_tsquery('english', 'inflation') query WHERE n.search_vector @@ query`,
+	"getNewsByIdWithTopic": `SELECT n.id, t.name FROM (SELECT id, topic_id FROM news UNION ALL SELECT id, topic_id FROM news_archive) n LEFT JOIN topics t ON t.id = n.topic_id WHERE n.id = 1`,
+	"getNewsById":          `SELECT id, uuid, title, content, topic_id, created_at, updated_at FROM news WHERE id = 1`,
+	"getNewsByUUID":        `SELECT id, uuid, title, content, topic_id, created_at, updated_at FROM news WHERE uuid = '00000000-0000-0000-0000-000000000000'`,
+	"getAllNewsCursor":     `SELECT id FROM news n WHERE (n.created_at, n.id) < ('2024-01-01', 1) ORDER BY n.created_at DESC, n.id DESC LIMIT 21 OFFSET 0`,
+	"getAllNewsByTopicIDs": `SELECT id FROM news n WHERE n.topic_id = ANY('{1,2}') ORDER BY n.created_at DESC, n.id DESC LIMIT 21 OFFSET 0`,
+	"countNewsByTopic":     `SELECT COUNT(*) FROM news n WHERE n.topic_id = (SELECT id FROM topics WHERE id = 1)`,
+	"getNewsByTopic":       `SELECT id, uuid, title, content, topic_id, created_at, updated_at FROM news n WHERE n.topic_id = (SELECT id FROM topics WHERE id = 1) ORDER BY n.created_at DESC, n.id DESC LIMIT 20 OFFSET 40`,
+	"getNewsNeighbors":     `SELECT id, uuid, title FROM news WHERE topic_id = 1 AND (created_at, id) < ('2024-01-01', 1) ORDER BY created_at DESC, id DESC LIMIT 1`,
+	"archiveNews":          `SELECT id FROM news WHERE created_at < '2024-01-01' ORDER BY id LIMIT 500 FOR UPDATE SKIP LOCKED`,
+	"getArchivedNews":      `SELECT id, uuid, title, content, topic_id, created_at, updated_at FROM news_archive ORDER BY created_at DESC`,
+	"getArchivedById":      `SELECT id, uuid, title, content, topic_id, created_at, updated_at FROM news_archive WHERE id = 1`,
+	"deleteTopicGuard":     `WITH t AS (SELECT id FROM topics WHERE id = 1) SELECT (SELECT COUNT(*) FROM news WHERE topic_id IN (SELECT id FROM t)) + (SELECT COUNT(*) FROM news_archive WHERE topic_id IN (SELECT id FROM t))`,
+	"removeCollections":    `DELETE FROM news_collections WHERE news_id = 1`,
+	"shiftPositions":       `UPDATE news_collections SET position = position - 1 WHERE collection_id = 1 AND position > 3`,
+	"loadCollection": `SELECT n.id FROM news_collections nc JOIN (
+		SELECT id, FALSE AS archived FROM news UNION ALL SELECT id, TRUE AS archived FROM news_archive
+	) n ON n.id = nc.news_id WHERE nc.collection_id = 1 ORDER BY nc.position, nc.news_id`,
+	"getModerationFlags": `SELECT id, news_id, reasons, created_at FROM moderation_flags ORDER BY created_at DESC`,
+	"flagCascade":        `DELETE FROM moderation_flags WHERE news_id = 1`,
+}
+
+func TestQueryPlansUseIndexes(t *testing.T) {
+	requirePostgres(t)
+	tx, err := db.Begin()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer tx.Rollback()
+
+	// Seed enough rows that the statistics describe a real table, then
+	// take seq scans off the table so any that remain mean no index fits.
+	seed := []string{
+		`INSERT INTO topics (name) SELECT 'Plan Topic ' || g FROM generate_series(1, 20) g`,
+		`INSERT INTO news (title, content, topic_id, created_at)
+			SELECT 'Plan ' || g, 'Body', (SELECT MIN(id) FROM topics WHERE name LIKE 'Plan Topic %') + g % 20,
+				TIMESTAMP '2020-01-01' + g * INTERVAL '1 minute'
+			FROM generate_series(1, 5000) g`,
+		`INSERT INTO news_archive (id, title, content, topic_id, created_at)
+			SELECT 100000000 + g, 'Archived ' || g, 'Body', (SELECT MIN(id) FROM topics WHERE name LIKE 'Plan Topic %'),
+				TIMESTAMP '2019-01-01' + g * INTERVAL '1 minute'
+			FROM generate_series(1, 5000) g`,
+		`INSERT INTO collections (title, slug) SELECT 'Plan ' || g, 'plan-' || g FROM generate_series(1, 50) g`,
+		`INSERT INTO news_collections (collection_id, news_id, position)
+			SELECT c.id, n.id, ROW_NUMBER() OVER (PARTITION BY c.id ORDER BY n.id)
+			FROM collections c JOIN news n ON n.id % 50 = c.id % 50
+			WHERE c.slug LIKE 'plan-%' AND n.title LIKE 'Plan %'`,
+		`INSERT INTO moderation_flags (news_id, reasons)
+			SELECT id, ARRAY['seed'] FROM news WHERE title LIKE 'Plan %'`,
+		`ANALYZE news, news_archive, news_collections, moderation_flags`,
+		`SET LOCAL enable_seqscan = off`,
+	}
+	for _, stmt := range seed {
+		_, err := tx.Exec(stmt)
+		if !assert.NoError(t, err, stmt) {
+			return
+		}
+	}
+
+	for name, query := range planQueries {
+		rows, err := tx.Query("EXPLAIN " + query)
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+
+		var plan []string
+		for rows.Next() {
+			var line string
+			assert.NoError(t, rows.Scan(&line))
+			plan = append(plan, line)
+		}
+		rows.Close()
+
+		text := strings.Join(plan, "\n")
+		assert.False(t, largeTableScan.MatchString(text), "%s scans a large table:\n%s", name, text)
+	}
+}
@@ -0,0 +1,252 @@
+// integrity.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// integritySampleSize caps the ids a report lists per check
+const integritySampleSize = 10
+
+// uncategorizedTopic receives articles whose topic is gone
+const uncategorizedTopic = "Uncategorized"
+
+// querier is what integrity checks run against: the pool when reporting,
+// a transaction when repairing
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// integrityCheck finds one kind of inconsistency. Find returns how many
+// rows are affected and a sample of their ids. Repair, when set, fixes
+// them inside the given transaction and returns the rows it changed;
+// checks without a safe fix only report.
+type integrityCheck struct {
+	Name        string
+	Description string
+	Find        func(ctx context.Context, q querier) (count int64, sample []int, err error)
+	Repair      func(ctx context.Context, tx *sql.Tx) (int64, error)
+}
+
+// integrityChecks run in registration order
+var integrityChecks []integrityCheck
+
+// registerIntegrityCheck adds a check to /api/admin/integrity. Features
+// with their own tables register theirs from an init function.
+func registerIntegrityCheck(check integrityCheck) {
+	integrityChecks = append(integrityChecks, check)
+}
+
+// sqlFinder is a Find for a query selecting the id of every violating row
+func sqlFinder(query string) func(ctx context.Context, q querier) (int64, []int, error) {
+	return func(ctx context.Context, q querier) (int64, []int, error) {
+		rows, err := q.QueryContext(ctx, `
+			SELECT COUNT(*) OVER (), id
+			FROM (`+query+`) v
+			ORDER BY id
+			LIMIT $1`, integritySampleSize)
+		if err != nil {
+			return 0, nil, err
+		}
+		defer rows.Close()
+		var count int64
+		sample := []int{}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&count, &id); err != nil {
+				return 0, nil, err
+			}
+			sample = append(sample, id)
+		}
+		return count, sample, rows.Err()
+	}
+}
+
+func init() {
+	// The foreign key stops topic_id naming a missing topic, but it is
+	// nullable, and rows from before the constraint may predate it
+	registerIntegrityCheck(integrityCheck{
+		Name:        "news_without_topic",
+		Description: "Articles, live or archived, whose topic is missing; repair moves them to " + uncategorizedTopic,
+		Find: sqlFinder(`
+			SELECT n.id FROM news n LEFT JOIN topics t ON t.id = n.topic_id WHERE t.id IS NULL
+			UNION ALL
+			SELECT n.id FROM news_archive n LEFT JOIN topics t ON t.id = n.topic_id WHERE t.id IS NULL`),
+		Repair: func(ctx context.Context, tx *sql.Tx) (int64, error) {
+			now := timestamp()
+			var topicID int
+			err := tx.QueryRowContext(ctx, `
+				INSERT INTO topics (name, description, created_at, updated_at)
+				VALUES ($1, 'Articles whose topic was lost', $2, $2)
+				ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+				RETURNING id
+			`, uncategorizedTopic, now).Scan(&topicID)
+			if err != nil {
+				return 0, err
+			}
+			var repaired int64
+			for _, table := range []string{"news", "news_archive"} {
+				res, err := tx.ExecContext(ctx, `
+					UPDATE `+table+` n SET topic_id = $1, updated_at = $2
+					WHERE NOT EXISTS (SELECT 1 FROM topics t WHERE t.id = n.topic_id)
+				`, topicID, now)
+				if err != nil {
+					return 0, err
+				}
+				n, _ := res.RowsAffected()
+				repaired += n
+			}
+			return repaired, nil
+		},
+	})
+
+	// news_collections.news_id has no foreign key since members may be
+	// archived, so a missed cleanup leaves entries pointing nowhere
+	registerIntegrityCheck(integrityCheck{
+		Name:        "collection_entries_without_news",
+		Description: "Collection entries whose article no longer exists; the ids are news ids, repair deletes the entries",
+		Find: sqlFinder(`
+			SELECT nc.news_id AS id FROM news_collections nc
+			WHERE NOT EXISTS (SELECT 1 FROM news n WHERE n.id = nc.news_id)
+			AND NOT EXISTS (SELECT 1 FROM news_archive a WHERE a.id = nc.news_id)`),
+		Repair: func(ctx context.Context, tx *sql.Tx) (int64, error) {
+			res, err := tx.ExecContext(ctx, `
+				DELETE FROM news_collections nc
+				WHERE NOT EXISTS (SELECT 1 FROM news n WHERE n.id = nc.news_id)
+				AND NOT EXISTS (SELECT 1 FROM news_archive a WHERE a.id = nc.news_id)
+			`)
+			if err != nil {
+				return 0, err
+			}
+			return res.RowsAffected()
+		},
+	})
+}
+
+// IntegrityResult is one check's findings
+type IntegrityResult struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Violations  int64  `json:"violations"`
+	SampleIDs   []int  `json:"sample_ids"`
+	Repairable  bool   `json:"repairable"`
+}
+
+// IntegrityRepair is what repairing one check changed, or would have
+// changed in a dry run
+type IntegrityRepair struct {
+	Name       string `json:"name"`
+	Violations int64  `json:"violations"`
+	Repaired   int64  `json:"repaired"`
+	DryRun     bool   `json:"dry_run"`
+}
+
+// selectedChecks returns the checks named by ?check=, all of them when it
+// is absent
+func selectedChecks(c echo.Context) ([]integrityCheck, bool) {
+	names := c.QueryParams()["check"]
+	if len(names) == 0 {
+		return integrityChecks, true
+	}
+	var checks []integrityCheck
+	for _, name := range names {
+		found := false
+		for _, check := range integrityChecks {
+			if check.Name == name {
+				checks = append(checks, check)
+				found = true
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return checks, true
+}
+
+func getIntegrity(c echo.Context) error {
+	checks, ok := selectedChecks(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidParameter, Message: "Unknown integrity check"})
+	}
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	results := []IntegrityResult{}
+	for _, check := range checks {
+		count, sample, err := check.Find(ctx, db)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to run integrity check " + check.Name})
+		}
+		results = append(results, IntegrityResult{
+			Name:        check.Name,
+			Description: check.Description,
+			Violations:  count,
+			SampleIDs:   sample,
+			Repairable:  check.Repair != nil,
+		})
+	}
+	return respondList(c, results)
+}
+
+// repairIntegrity applies the fix of every selected check that has one,
+// each in its own transaction. With ?dry_run=true the fixes run and are
+// rolled back, so the counts are exactly what a real repair would change.
+func repairIntegrity(c echo.Context) error {
+	checks, ok := selectedChecks(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidParameter, Message: "Unknown integrity check"})
+	}
+	dryRun := false
+	if v := c.QueryParam("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidParameter, Message: "dry_run must be a boolean"})
+		}
+	}
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	repairs := []IntegrityRepair{}
+	for _, check := range checks {
+		if check.Repair == nil {
+			continue
+		}
+		repair, err := runRepair(ctx, check, dryRun)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to repair " + check.Name})
+		}
+		repairs = append(repairs, repair)
+	}
+	return respondList(c, repairs)
+}
+
+func runRepair(ctx context.Context, check integrityCheck, dryRun bool) (IntegrityRepair, error) {
+	repair := IntegrityRepair{Name: check.Name, DryRun: dryRun}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return repair, err
+	}
+	defer tx.Rollback()
+
+	if repair.Violations, _, err = check.Find(ctx, tx); err != nil {
+		return repair, err
+	}
+	if repair.Violations == 0 {
+		return repair, nil
+	}
+	if repair.Repaired, err = check.Repair(ctx, tx); err != nil {
+		return repair, err
+	}
+	if dryRun {
+		return repair, nil
+	}
+	return repair, tx.Commit()
+}
@@ -0,0 +1,97 @@
+// integrity_test.go
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntegrityCheckNamesUnique(t *testing.T) {
+	seen := map[string]bool{}
+	for _, check := range integrityChecks {
+		assert.False(t, seen[check.Name], check.Name)
+		seen[check.Name] = true
+		assert.NotNil(t, check.Find, check.Name)
+	}
+}
+
+func TestIntegrityReportAndRepair(t *testing.T) {
+	requirePostgres(t)
+	s := newTestServer(t)
+
+	var orphanID int
+	err := db.QueryRow(`INSERT INTO news (title, content) VALUES ('Orphan', 'No topic') RETURNING id`).Scan(&orphanID)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Exec("DELETE FROM news WHERE id = $1", orphanID)
+
+	var collectionID int
+	err = db.QueryRow(`INSERT INTO collections (title, slug) VALUES ('Integrity', 'integrity-test') RETURNING id`).Scan(&collectionID)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Exec("DELETE FROM collections WHERE id = $1", collectionID)
+	_, err = db.Exec(`INSERT INTO news_collections (collection_id, news_id, position) VALUES ($1, $2, 1)`, collectionID, orphanID+1000000)
+	assert.NoError(t, err)
+
+	// The sequence checks have their own test
+	const checks = "?check=news_without_topic&check=collection_entries_without_news"
+	var report []IntegrityResult
+	if s.expect(s.do(http.MethodGet, "/api/admin/integrity"+checks, nil), http.StatusOK, &report) && assert.Len(t, report, 2) {
+		for _, r := range report {
+			assert.GreaterOrEqual(t, r.Violations, int64(1), r.Name)
+			assert.NotEmpty(t, r.SampleIDs, r.Name)
+			assert.True(t, r.Repairable, r.Name)
+		}
+	}
+
+	// A dry run counts what it would fix and changes nothing
+	var repairs []IntegrityRepair
+	if s.expect(s.do(http.MethodPost, "/api/admin/integrity/repair"+checks+"&dry_run=true", nil), http.StatusOK, &repairs) {
+		for _, r := range repairs {
+			assert.True(t, r.DryRun)
+			assert.GreaterOrEqual(t, r.Repaired, int64(1), r.Name)
+		}
+	}
+	var topicID *int
+	db.QueryRow("SELECT topic_id FROM news WHERE id = $1", orphanID).Scan(&topicID)
+	assert.Nil(t, topicID)
+
+	if s.expect(s.do(http.MethodPost, "/api/admin/integrity/repair"+checks, nil), http.StatusOK, &repairs) {
+		for _, r := range repairs {
+			assert.False(t, r.DryRun)
+			assert.GreaterOrEqual(t, r.Repaired, int64(1), r.Name)
+		}
+	}
+	var topic string
+	err = db.QueryRow("SELECT t.name FROM news n JOIN topics t ON t.id = n.topic_id WHERE n.id = $1", orphanID).Scan(&topic)
+	assert.NoError(t, err)
+	assert.Equal(t, uncategorizedTopic, topic)
+	defer db.Exec("DELETE FROM topics WHERE name = $1", uncategorizedTopic)
+
+	var entries int
+	db.QueryRow("SELECT COUNT(*) FROM news_collections WHERE collection_id = $1", collectionID).Scan(&entries)
+	assert.Zero(t, entries)
+
+	if s.expect(s.do(http.MethodGet, "/api/admin/integrity"+checks, nil), http.StatusOK, &report) {
+		for _, r := range report {
+			assert.Zero(t, r.Violations, r.Name)
+			assert.Empty(t, r.SampleIDs, r.Name)
+		}
+	}
+}
+
+func TestIntegrityUnknownCheck(t *testing.T) {
+	requirePostgres(t)
+	s := newTestServer(t)
+	var body ErrorResponse
+	if s.expect(s.do(http.MethodGet, "/api/admin/integrity?check=nope", nil), http.StatusBadRequest, &body) {
+		assert.Equal(t, codeInvalidParameter, body.Code)
+	}
+	if s.expect(s.do(http.MethodPost, "/api/admin/integrity/repair?dry_run=maybe", nil), http.StatusBadRequest, &body) {
+		assert.Equal(t, codeInvalidParameter, body.Code)
+	}
+}
@@ -0,0 +1,95 @@
+// logging.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// setupLogging makes slog's default logger write one JSON object per
+// line to stderr at the level LOG_LEVEL names: debug, info (the
+// default), warn or error. The standard log package writes through it
+// too.
+func setupLogging() {
+	level := new(slog.LevelVar)
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			fatalf("Invalid LOG_LEVEL %q: must be debug, info, warn or error", v)
+		}
+	}
+}
+
+// logLevel is the configured LOG_LEVEL, for the resolved configuration
+func logLevel() string {
+	for _, l := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError} {
+		if slog.Default().Enabled(context.Background(), l) {
+			return strings.ToLower(l.String())
+		}
+	}
+	return "error"
+}
+
+// fatal logs msg with its attributes at error level and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// fatalf is fatal for a formatted message, as configuration loaders
+// report invalid values
+func fatalf(format string, args ...any) {
+	fatal(fmt.Sprintf(format, args...))
+}
+
+// requestLog returns the default logger tagged with the id of the request
+// behind ctx, so a reported failure can be matched to its log lines
+func requestLog(ctx context.Context) *slog.Logger {
+	if id := requestID(ctx); id != "" {
+		return slog.Default().With("request_id", id)
+	}
+	return slog.Default()
+}
+
+// logRequests writes one access log entry per request: error level for
+// 5xx responses, warn for 4xx and info otherwise
+func logRequests(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		err := next(c)
+		if err != nil {
+			// Render the error now so the entry records the real status
+			c.Error(err)
+		}
+
+		req, res := c.Request(), c.Response()
+		level := slog.LevelInfo
+		switch {
+		case res.Status >= 500:
+			level = slog.LevelError
+		case res.Status >= 400:
+			level = slog.LevelWarn
+		}
+		attrs := []any{
+			"method", req.Method,
+			"route", c.Path(),
+			"uri", req.RequestURI,
+			"status", res.Status,
+			"latency_ms", float64(time.Since(start)) / float64(time.Millisecond),
+			"bytes_in", req.ContentLength,
+			"bytes_out", res.Size,
+			"remote_ip", c.RealIP(),
+		}
+		if err != nil {
+			attrs = append(attrs, "error", err.Error())
+		}
+		requestLog(req.Context()).Log(req.Context(), level, "request", attrs...)
+		return nil
+	}
+}
@@ -0,0 +1,110 @@
+// logging_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// captureLogs sends the default logger's JSON entries to the returned
+// buffer for the rest of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	saved := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(saved) })
+	return &buf
+}
+
+func logEntries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var entries []map[string]interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var entry map[string]interface{}
+		if assert.NoError(t, json.Unmarshal(line, &entry), string(line)) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func TestRequestLogTagsRequestID(t *testing.T) {
+	buf := captureLogs(t)
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "abc")
+	requestLog(ctx).Error("Something failed", "error", "boom")
+	requestLog(context.Background()).Info("Outside a request")
+
+	entries := logEntries(t, buf)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "ERROR", entries[0]["level"])
+		assert.Equal(t, "abc", entries[0]["request_id"])
+		assert.Equal(t, "boom", entries[0]["error"])
+		assert.NotContains(t, entries[1], "request_id")
+	}
+}
+
+func TestLogRequests(t *testing.T) {
+	saved := db
+	db = nil
+	defer func() { db = saved }()
+	e := newRouter()
+	buf := captureLogs(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/integrity?check=x", nil)
+	req.Header.Set(echo.HeaderXRequestID, "req-1")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logEntries(t, buf)
+	if assert.Len(t, entries, 1) {
+		entry := entries[0]
+		assert.Equal(t, "request", entry["msg"])
+		assert.Equal(t, "ERROR", entry["level"])
+		assert.Equal(t, "GET", entry["method"])
+		assert.Equal(t, "/api/admin/integrity", entry["route"])
+		assert.Equal(t, "/api/admin/integrity?check=x", entry["uri"])
+		assert.Equal(t, float64(http.StatusNotImplemented), entry["status"])
+		assert.Equal(t, "req-1", entry["request_id"])
+		assert.Contains(t, entry, "latency_ms")
+		assert.Greater(t, entry["bytes_out"], float64(0))
+	}
+}
+
+// Errors returned by handlers are rendered before the entry is written,
+// so it records the status the client saw
+func TestLogRequestsRendersErrors(t *testing.T) {
+	e := newRouter()
+	buf := captureLogs(t)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/health", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	entries := logEntries(t, buf)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "WARN", entries[0]["level"])
+		assert.Equal(t, float64(http.StatusMethodNotAllowed), entries[0]["status"])
+		assert.Contains(t, entries[0], "error")
+	}
+}
+
+func TestSetupLoggingLevel(t *testing.T) {
+	saved := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(saved) })
+
+	t.Setenv("LOG_LEVEL", "warn")
+	setupLogging()
+	assert.Equal(t, "warn", logLevel())
+	assert.False(t, slog.Default().Enabled(context.Background(), slog.LevelInfo))
+
+	t.Setenv("LOG_LEVEL", "")
+	setupLogging()
+	assert.Equal(t, "info", logLevel())
+}
@@ -0,0 +1,410 @@
+// memstore.go
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Column limits the schema enforces, mirrored by memStore
+const (
+	maxTopicNameChars = 100
+	maxNewsTitleChars = 200
+)
+
+// memStore is a Store kept in process memory, for demos (STORAGE=memory)
+// and fast tests. It applies the same ids, reference checks, uniqueness
+// and timestamps as Postgres. Nothing is ever archived, and moderation
+// flags are not kept since only the Postgres admin endpoint reads them.
+type memStore struct {
+	mu                  sync.Mutex
+	news                map[int]News
+	topics              map[int]Topic
+	lastNews, lastTopic int
+}
+
+func newMemStore() *memStore {
+	return &memStore{news: map[int]News{}, topics: map[int]Topic{}}
+}
+
+// newUUID returns a random version 4 UUID, as gen_random_uuid does
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// matchID reports whether a path id, an integer or a UUID, names the row
+func matchID(param string, id int, uuid string) bool {
+	if isUUID(param) {
+		return strings.EqualFold(param, uuid)
+	}
+	n, err := strconv.Atoi(param)
+	return err == nil && n == id
+}
+
+func (s *memStore) findNews(id string) (News, bool) {
+	for _, n := range s.news {
+		if matchID(id, n.ID, n.UUID) {
+			return n, true
+		}
+	}
+	return News{}, false
+}
+
+func (s *memStore) findTopic(id string) (Topic, bool) {
+	for _, t := range s.topics {
+		if matchID(id, t.ID, t.UUID) {
+			return t, true
+		}
+	}
+	return Topic{}, false
+}
+
+func (s *memStore) topicNamed(name string, except int) bool {
+	for _, t := range s.topics {
+		if t.Name == name && t.ID != except {
+			return true
+		}
+	}
+	return false
+}
+
+// item shapes an article the way newsSelect does: the full News, or a map
+// of the requested fields
+func (s *memStore) item(n News, fields []string, includeTopic bool) interface{} {
+	var topic *TopicSummary
+	if includeTopic {
+		if t, ok := s.topics[n.TopicID]; ok {
+			topic = t.summary()
+		}
+	}
+	if fields == nil {
+		n.Topic = topic
+		return n
+	}
+
+	values := map[string]interface{}{
+		"id":         n.ID,
+		"uuid":       n.UUID,
+		"title":      n.Title,
+		"content":    n.Content,
+		"topic_id":   n.TopicID,
+		"created_at": n.CreatedAt,
+		"updated_at": n.UpdatedAt,
+		"created_by": n.CreatedBy,
+		"archived":   n.Archived,
+	}
+	item := make(map[string]interface{}, len(fields)+1)
+	for _, name := range fields {
+		item[name] = values[name]
+	}
+	if includeTopic {
+		item["topic"] = topic
+	}
+	return item
+}
+
+func (s *memStore) GetNews(ctx context.Context, id string, fields []string, includeTopic bool) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.findNews(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.item(n, fields, includeTopic), nil
+}
+
+// newsLess orders articles by one of newsSortColumns, ties broken on id in
+// the same direction
+func newsLess(order Sort, a, b News) bool {
+	var cmp int
+	switch order.Column {
+	case "n.created_at":
+		cmp = a.CreatedAt.Compare(b.CreatedAt)
+	case "n.updated_at":
+		cmp = a.UpdatedAt.Compare(b.UpdatedAt)
+	case "n.title":
+		cmp = strings.Compare(a.Title, b.Title)
+	}
+	if cmp == 0 {
+		cmp = a.ID - b.ID
+	}
+	if order.Desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+func (s *memStore) ListNews(ctx context.Context, q NewsQuery, fields []string, withTotal bool) ([]interface{}, ListMeta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var meta ListMeta
+
+	scope := -1
+	if q.Topic != "" {
+		if t, ok := s.findTopic(q.Topic); ok {
+			scope = t.ID
+		} else {
+			scope = 0
+		}
+	}
+	search := strings.ToLower(q.Search)
+
+	var matched []News
+	for _, n := range s.news {
+		switch {
+		case scope >= 0 && n.TopicID != scope,
+			!q.Dates.From.IsZero() && n.CreatedAt.Before(q.Dates.From),
+			!q.Dates.Until.IsZero() && !n.CreatedAt.Before(q.Dates.Until),
+			search != "" && !strings.Contains(strings.ToLower(n.Title), search) && !strings.Contains(strings.ToLower(n.Content), search),
+			q.TopicIDs != nil && !containsInt(q.TopicIDs, n.TopicID):
+			continue
+		}
+		matched = append(matched, n)
+	}
+	sort.Slice(matched, func(i, j int) bool { return newsLess(q.Sort, matched[i], matched[j]) })
+
+	if withTotal {
+		total := int64(len(matched))
+		meta.Total = &total
+	}
+
+	page := q.Page
+	if page.After != nil {
+		after := News{ID: page.After.ID, CreatedAt: page.After.CreatedAt}
+		i := sort.Search(len(matched), func(i int) bool { return newsLess(defaultNewsSort, after, matched[i]) })
+		matched = matched[i:]
+	}
+	if page.Offset >= len(matched) {
+		matched = nil
+	} else {
+		matched = matched[page.Offset:]
+	}
+	if len(matched) > page.Limit {
+		matched = matched[:page.Limit]
+		if q.keyset() {
+			last := matched[page.Limit-1]
+			meta.NextCursor = newsCursor{CreatedAt: last.CreatedAt, ID: last.ID}.encode()
+		}
+	}
+
+	var items []interface{}
+	for _, n := range matched {
+		items = append(items, s.item(n, fields, q.IncludeTopic))
+	}
+	return items, meta, nil
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateNews resolves the topic as resolveTopic does: by id, by name, or
+// by creating the name when inline creation is enabled
+func (s *memStore) CreateNews(ctx context.Context, req CreateNewsRequest, verdict ModerationResult) (News, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if utf8.RuneCountInString(req.Title) > maxNewsTitleChars {
+		return News{}, &InvalidValueError{Problem: "too long"}
+	}
+
+	var topic Topic
+	var found bool
+	if req.TopicName == "" {
+		topic, found = s.topics[req.TopicID]
+	} else {
+		for _, t := range s.topics {
+			if t.Name == req.TopicName {
+				topic, found = t, true
+			}
+		}
+		if !found && inlineTopicCreation {
+			var err error
+			if topic, err = s.insertTopic(Topic{Name: req.TopicName}); err != nil {
+				return News{}, err
+			}
+			found = true
+		}
+	}
+	if !found {
+		return News{}, &ForeignKeyError{Ref: "topic"}
+	}
+
+	now := timestamp()
+	s.lastNews++
+	created := News{ID: s.lastNews, UUID: newUUID(), Title: req.Title, Content: req.Content, TopicID: topic.ID, CreatedAt: now, UpdatedAt: now, CreatedBy: req.CreatedBy}
+	s.news[created.ID] = created
+	created.Topic = topic.summary()
+	return created, nil
+}
+
+func (s *memStore) UpdateNews(ctx context.Context, id string, change NewsChange, verdict ModerationResult) (News, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if change.TopicID != nil {
+		if _, ok := s.topics[*change.TopicID]; !ok {
+			return News{}, &ForeignKeyError{Ref: "topic"}
+		}
+	}
+	n, ok := s.findNews(id)
+	if !ok {
+		return News{}, ErrNotFound
+	}
+	if change.Title != nil && utf8.RuneCountInString(*change.Title) > maxNewsTitleChars {
+		return News{}, &InvalidValueError{Problem: "too long"}
+	}
+
+	before := n
+	if change.Title != nil {
+		n.Title = *change.Title
+	}
+	if change.Content != nil {
+		n.Content = *change.Content
+	}
+	if change.TopicID != nil {
+		n.TopicID = *change.TopicID
+	}
+	if change.Replace || n != before {
+		n.UpdatedAt = timestamp()
+	}
+	s.news[n.ID] = n
+	return n, nil
+}
+
+func (s *memStore) DeleteNews(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.findNews(id)
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.news, n.ID)
+	return nil
+}
+
+// topicLess orders topics by one of topicSortColumns, ties broken on id
+func topicLess(order Sort, a, b Topic) bool {
+	var cmp int
+	switch order.Column {
+	case "name":
+		cmp = strings.Compare(a.Name, b.Name)
+	case "created_at":
+		cmp = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if cmp == 0 {
+		cmp = a.ID - b.ID
+	}
+	if order.Desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+func (s *memStore) ListTopics(ctx context.Context, order Sort) ([]Topic, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var topics []Topic
+	for _, t := range s.topics {
+		topics = append(topics, t)
+	}
+	sort.Slice(topics, func(i, j int) bool { return topicLess(order, topics[i], topics[j]) })
+	return topics, nil
+}
+
+func (s *memStore) GetTopic(ctx context.Context, id string) (Topic, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.findTopic(id)
+	if !ok {
+		return Topic{}, ErrNotFound
+	}
+	return t, nil
+}
+
+func (s *memStore) CreateTopic(ctx context.Context, topic Topic) (Topic, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.insertTopic(topic)
+}
+
+func (s *memStore) insertTopic(topic Topic) (Topic, error) {
+	if utf8.RuneCountInString(topic.Name) > maxTopicNameChars {
+		return Topic{}, &InvalidValueError{Problem: "too long"}
+	}
+	if s.topicNamed(topic.Name, 0) {
+		return Topic{}, &DuplicateError{Field: "name"}
+	}
+	now := timestamp()
+	s.lastTopic++
+	t := Topic{
+		ID:              s.lastTopic,
+		UUID:            newUUID(),
+		Name:            topic.Name,
+		Tagline:         topic.Tagline,
+		LongDescription: topic.LongDescription,
+		MetaTitle:       topic.MetaTitle,
+		MetaDescription: topic.MetaDescription,
+		HeaderImageURL:  topic.HeaderImageURL,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	s.topics[t.ID] = t
+	return t, nil
+}
+
+func (s *memStore) UpdateTopic(ctx context.Context, id string, change TopicChange) (Topic, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.findTopic(id)
+	if !ok {
+		return Topic{}, ErrNotFound
+	}
+	if change.Name != nil {
+		if utf8.RuneCountInString(*change.Name) > maxTopicNameChars {
+			return Topic{}, &InvalidValueError{Problem: "too long"}
+		}
+		if s.topicNamed(*change.Name, t.ID) {
+			return Topic{}, &DuplicateError{Field: "name"}
+		}
+	}
+
+	before := t
+	change.apply(&t)
+	if change.Replace || t != before {
+		t.UpdatedAt = timestamp()
+	}
+	s.topics[t.ID] = t
+	return t, nil
+}
+
+func (s *memStore) DeleteTopic(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.findTopic(id)
+	if !ok {
+		return ErrNotFound
+	}
+	for _, n := range s.news {
+		if n.TopicID == t.ID {
+			return ErrInUse
+		}
+	}
+	delete(s.topics, t.ID)
+	return nil
+}
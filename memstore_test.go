@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// forEachStore runs fn through the real router once per Store, so every
+// backend is held to the same expectations. SQLite gets a fresh file per
+// run; MySQL runs against MYSQL_TEST_URL and Postgres against the test
+// database, each skipped when not configured.
+func forEachStore(t *testing.T, fn func(t *testing.T, s *testServer)) {
+	t.Run("memory", func(t *testing.T) {
+		useStore(t, newMemStore())
+		fn(t, newTestServer(t))
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		useStore(t, openTestSQLite(t, filepath.Join(t.TempDir(), "news.db")))
+		fn(t, newTestServer(t))
+	})
+	t.Run("mysql", func(t *testing.T) {
+		useStore(t, openTestMySQL(t))
+		fn(t, newTestServer(t))
+	})
+	t.Run("postgres", func(t *testing.T) {
+		requirePostgres(t)
+		useStore(t, newPGStore(db))
+		fn(t, newTestServer(t))
+	})
+}
+
+// mustCreate posts body to path, expecting 201, and deletes the result
+// through itemPath when the test ends
+func mustCreate(t *testing.T, s *testServer, path string, body interface{}, v interface{}, itemPath func() string) bool {
+	if !s.expect(s.do(http.MethodPost, path, body), http.StatusCreated, v) {
+		return false
+	}
+	t.Cleanup(func() { s.do(http.MethodDelete, itemPath(), nil) })
+	return true
+}
+
+func TestStoreTopicParity(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s *testServer) {
+		fc := useFakeClock(t, time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC))
+		name := uniqueName(t, "Parity")
+
+		var topic Topic
+		if !mustCreate(t, s, "/api/topics", map[string]string{"name": name}, &topic, func() string { return "/api/topics/" + strconv.Itoa(topic.ID) }) {
+			return
+		}
+		assert.NotEmpty(t, topic.UUID)
+		assert.True(t, topic.CreatedAt.Equal(fc.Now()))
+
+		var body ErrorResponse
+		s.expect(s.do(http.MethodPost, "/api/topics", map[string]string{"name": name}), http.StatusConflict, &body)
+		assert.Equal(t, codeDuplicate, body.Code)
+		s.expect(s.do(http.MethodPost, "/api/topics", map[string]string{"name": strings.Repeat("x", 101)}), http.StatusBadRequest, &body)
+		assert.Equal(t, codeValidationFailed, body.Code)
+
+		var got Topic
+		s.expect(s.do(http.MethodGet, "/api/topics/"+strings.ToUpper(topic.UUID), nil), http.StatusOK, &got)
+		assert.Equal(t, topic.ID, got.ID)
+
+		// PATCH only moves updated_at for real changes; PUT always does
+		fc.Advance(time.Minute)
+		s.expect(s.do(http.MethodPatch, "/api/topics/"+strconv.Itoa(topic.ID), map[string]string{"name": name}), http.StatusOK, &got)
+		assert.True(t, got.UpdatedAt.Equal(topic.UpdatedAt))
+		s.expect(s.do(http.MethodPatch, "/api/topics/"+strconv.Itoa(topic.ID), map[string]string{"description": "Changed"}), http.StatusOK, &got)
+		assert.True(t, got.UpdatedAt.Equal(fc.Now()))
+		assert.Equal(t, name, got.Name)
+
+		fc.Advance(time.Minute)
+		s.expect(s.do(http.MethodPut, "/api/topics/"+strconv.Itoa(topic.ID), map[string]string{"name": name, "description": "Changed"}), http.StatusOK, &got)
+		assert.True(t, got.UpdatedAt.Equal(fc.Now()))
+
+		s.expect(s.do(http.MethodPatch, "/api/topics/2147483647", map[string]string{"name": "Gone"}), http.StatusNotFound, &body)
+		assert.Equal(t, codeTopicNotFound, body.Code)
+	})
+}
+
+func TestStoreNewsParity(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s *testServer) {
+		var topic Topic
+		if !mustCreate(t, s, "/api/topics", map[string]string{"name": uniqueName(t, "Parity news")}, &topic, func() string { return "/api/topics/" + strconv.Itoa(topic.ID) }) {
+			return
+		}
+		var news News
+		article := map[string]interface{}{"title": "Parity", "content": "Body", "topic_id": topic.ID}
+		if !mustCreate(t, s, "/api/news", article, &news, func() string { return "/api/news/" + strconv.Itoa(news.ID) }) {
+			return
+		}
+		if assert.NotNil(t, news.Topic) {
+			assert.Equal(t, topic.ID, news.Topic.ID)
+		}
+
+		// By name, an existing topic is reused
+		var byName News
+		if mustCreate(t, s, "/api/news", map[string]interface{}{"title": "By name", "content": "Body", "topic_name": topic.Name}, &byName, func() string { return "/api/news/" + strconv.Itoa(byName.ID) }) {
+			assert.Equal(t, topic.ID, byName.TopicID)
+		}
+
+		var body ErrorResponse
+		missing := map[string]interface{}{"title": "T", "content": "C", "topic_id": 2147483647}
+		s.expect(s.do(http.MethodPost, "/api/news", missing), http.StatusBadRequest, &body)
+		assert.Equal(t, codeInvalidReference, body.Code)
+		assert.Equal(t, map[string]string{"topic_id": "does not exist"}, body.Details)
+		s.expect(s.do(http.MethodPatch, "/api/news/"+strconv.Itoa(news.ID), map[string]int{"topic_id": 2147483647}), http.StatusBadRequest, &body)
+		assert.Equal(t, codeInvalidReference, body.Code)
+
+		var sparse map[string]interface{}
+		s.expect(s.do(http.MethodGet, "/api/news/"+news.UUID+"?fields=id,title&include=topic", nil), http.StatusOK, &sparse)
+		assert.Equal(t, float64(news.ID), sparse["id"])
+		assert.Equal(t, "Parity", sparse["title"])
+		assert.NotContains(t, sparse, "content")
+		if embedded, ok := sparse["topic"].(map[string]interface{}); assert.True(t, ok) {
+			assert.Equal(t, topic.Name, embedded["name"])
+		}
+
+		s.expect(s.do(http.MethodDelete, "/api/topics/"+strconv.Itoa(topic.ID), nil), http.StatusConflict, &body)
+		assert.Equal(t, codeTopicInUse, body.Code)
+
+		s.expect(s.do(http.MethodDelete, "/api/news/"+strconv.Itoa(byName.ID), nil), http.StatusOK, nil)
+		s.expect(s.do(http.MethodGet, "/api/news/"+strconv.Itoa(byName.ID), nil), http.StatusNotFound, &body)
+		assert.Equal(t, codeNewsNotFound, body.Code)
+	})
+}
+
+func TestStoreListingParity(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s *testServer) {
+		fc := useFakeClock(t, time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC))
+		var topic Topic
+		if !mustCreate(t, s, "/api/topics", map[string]string{"name": uniqueName(t, "Parity listing")}, &topic, func() string { return "/api/topics/" + strconv.Itoa(topic.ID) }) {
+			return
+		}
+		ids := map[string]int{}
+		for _, title := range []string{"Bravo", "Alpha", "Charlie"} {
+			var news News
+			article := map[string]interface{}{"title": title, "content": "Body of " + strings.ToLower(title), "topic_id": topic.ID}
+			if !mustCreate(t, s, "/api/news", article, &news, func() string { return "/api/news/" + strconv.Itoa(news.ID) }) {
+				return
+			}
+			ids[title] = news.ID
+			fc.Advance(time.Hour)
+		}
+
+		list := func(query string) ([]News, *httptest.ResponseRecorder) {
+			var items []News
+			rec := s.do(http.MethodGet, "/api/news/topic/"+strconv.Itoa(topic.ID)+query, nil)
+			s.expect(rec, http.StatusOK, &items)
+			return items, rec
+		}
+		titles := func(items []News) []string {
+			var out []string
+			for _, n := range items {
+				out = append(out, n.Title)
+			}
+			return out
+		}
+
+		// Newest first, paged by cursor
+		items, rec := list("?limit=2")
+		assert.Equal(t, []string{"Charlie", "Alpha"}, titles(items))
+		assert.Equal(t, "3", rec.Header().Get("X-Total-Count"))
+		cursor := rec.Header().Get("X-Next-Cursor")
+		if assert.NotEmpty(t, cursor) {
+			items, rec = list("?limit=2&cursor=" + cursor)
+			assert.Equal(t, []string{"Bravo"}, titles(items))
+			assert.Empty(t, rec.Header().Get("X-Next-Cursor"))
+		}
+
+		items, _ = list("?sort=title&offset=1")
+		assert.Equal(t, []string{"Bravo", "Charlie"}, titles(items))
+		items, _ = list("?q=OF+ALPHA")
+		assert.Equal(t, []string{"Alpha"}, titles(items))
+		items, _ = list("?from=2024-06-01T09:00:00Z&to=2024-06-01T09:30:00Z")
+		assert.Equal(t, []string{"Alpha"}, titles(items))
+		items, _ = list("?topic_ids=" + strconv.Itoa(topic.ID) + ",2147483647&sort=created_at")
+		assert.Equal(t, []string{"Bravo", "Alpha", "Charlie"}, titles(items))
+
+		var minimal []map[string]interface{}
+		req := httptest.NewRequest(http.MethodGet, "/api/news/topic/"+topic.UUID+"?limit=1", nil)
+		req.Header.Set("Prefer", "return=minimal")
+		rec = httptest.NewRecorder()
+		s.e.ServeHTTP(rec, req)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &minimal))
+		if assert.Len(t, minimal, 1) {
+			assert.Equal(t, float64(ids["Charlie"]), minimal[0]["id"])
+			assert.NotContains(t, minimal[0], "title")
+		}
+		assert.Empty(t, rec.Header().Get("X-Total-Count"))
+
+		rec = s.do(http.MethodGet, "/api/news/topic/00000000-0000-4000-8000-000000000000", nil)
+		assert.Equal(t, "[]", strings.TrimSpace(rec.Body.String()))
+	})
+}
+
+// Routes outside the Store answer 501 when there is no database
+func TestNeedsDatabase(t *testing.T) {
+	saved := db
+	db = nil
+	defer func() { db = saved }()
+
+	s := newTestServer(t)
+	var body ErrorResponse
+	s.expect(s.do(http.MethodGet, "/api/collections", nil), http.StatusNotImplemented, &body)
+	assert.Equal(t, "NOT_IMPLEMENTED", body.Code)
+	s.expect(s.do(http.MethodGet, "/api/news/search?q=x", nil), http.StatusNotImplemented, nil)
+}
@@ -0,0 +1,128 @@
+// middleware.go
+package main
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// normalizePath collapses duplicate slashes and strips a trailing slash
+// before routing. GET and HEAD requests are redirected with a 308 to the
+// canonical path so caches and clients learn it; other methods are
+// rewritten in place since redirecting a body-carrying request is unsafe.
+func normalizePath(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		path := canonicalPath(req.URL.Path)
+		if path == req.URL.Path {
+			return next(c)
+		}
+
+		// The target keeps the escaping, so %2F and %3F stay part of a
+		// segment rather than becoming a separator or a query
+		if req.Method == http.MethodGet || req.Method == http.MethodHead {
+			target := canonicalPath(req.URL.EscapedPath())
+			if req.URL.RawQuery != "" {
+				target += "?" + req.URL.RawQuery
+			}
+			return c.Redirect(http.StatusPermanentRedirect, target)
+		}
+
+		req.URL.Path = path
+		if req.URL.RawPath != "" {
+			req.URL.RawPath = canonicalPath(req.URL.RawPath)
+		}
+		return next(c)
+	}
+}
+
+// canonicalPath returns p with runs of slashes collapsed and any trailing
+// slash removed. The root path is left as "/". Collapsing leading slashes
+// also guarantees a redirect target can never become a protocol-relative
+// URL such as //example.com.
+func canonicalPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+
+	var b strings.Builder
+	b.Grow(len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] == '/' && i > 0 && p[i-1] == '/' {
+			continue
+		}
+		b.WriteByte(p[i])
+	}
+
+	out := b.String()
+	if len(out) > 1 && strings.HasSuffix(out, "/") {
+		out = strings.TrimSuffix(out, "/")
+	}
+	return out
+}
+
+// needsDatabase guards routes the Store does not cover. With
+// STORAGE=memory there is no database, so they answer 501 instead of
+// failing on a nil connection.
+func needsDatabase(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if db == nil {
+			return c.JSON(http.StatusNotImplemented, ErrorResponse{Code: codeNotImplemented, Message: "Not available with in-memory storage"})
+		}
+		return next(c)
+	}
+}
+
+type requestIDKey struct{}
+
+// validRequestID limits incoming X-Request-ID values to what can be
+// written into a log line safely
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestIDs gives every request an id, taken from X-Request-ID when the
+// caller sent a usable one and generated otherwise. The id is echoed in
+// the response header, 5xx error bodies and, through requestLog, every
+// log entry written while serving the request.
+func requestIDs(next echo.HandlerFunc) echo.HandlerFunc {
+	assign := middleware.RequestIDWithConfig(middleware.RequestIDConfig{
+		RequestIDHandler: func(c echo.Context, id string) {
+			req := c.Request()
+			c.SetRequest(req.WithContext(context.WithValue(req.Context(), requestIDKey{}, id)))
+		},
+	})(next)
+	return func(c echo.Context) error {
+		if id := c.Request().Header.Get(echo.HeaderXRequestID); id != "" && !validRequestID.MatchString(id) {
+			c.Request().Header.Del(echo.HeaderXRequestID)
+		}
+		return assign(c)
+	}
+}
+
+// requestID returns the id requestIDs assigned, "" outside a request
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// recoverPanics turns a panic into a 500 and logs it with the request id
+var recoverPanics = middleware.RecoverWithConfig(middleware.RecoverConfig{
+	LogErrorFunc: func(c echo.Context, err error, stack []byte) error {
+		requestLog(c.Request().Context()).Error("Panic recovered", "error", err, "stack", string(stack))
+		return err
+	},
+})
+
+// withRequestID adds the request id to error bodies of 5xx responses
+func withRequestID(c echo.Context, i interface{}) interface{} {
+	body, ok := i.(ErrorResponse)
+	if !ok || c.Response().Status < http.StatusInternalServerError {
+		return i
+	}
+	body.RequestID = requestID(c.Request().Context())
+	return body
+}
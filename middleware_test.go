@@ -0,0 +1,138 @@
+// middleware_test.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalPath(t *testing.T) {
+	cases := map[string]string{
+		"":                   "/",
+		"/":                  "/",
+		"//":                 "/",
+		"/api/news":          "/api/news",
+		"/api/news/":         "/api/news",
+		"/api//news":         "/api/news",
+		"//api///news//1//":  "/api/news/1",
+		"//example.com/path": "/example.com/path",
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, canonicalPath(in), "canonicalPath(%q)", in)
+	}
+}
+
+// Every route family redirects non-canonical GET/HEAD paths with a 308
+func TestNormalizePathRedirects(t *testing.T) {
+	e := newRouter()
+	cases := []struct {
+		method, path, location string
+	}{
+		{http.MethodGet, "/api/news/", "/api/news"},
+		{http.MethodGet, "/api//news", "/api/news"},
+		{http.MethodGet, "/api/news/1/", "/api/news/1"},
+		{http.MethodGet, "/api/news/topic//1", "/api/news/topic/1"},
+		{http.MethodGet, "/api/topics/", "/api/topics"},
+		{http.MethodGet, "//api/topics/1", "/api/topics/1"},
+		{http.MethodHead, "/health/", "/health"},
+		{http.MethodGet, "/api/news/?topic=1", "/api/news?topic=1"},
+		{http.MethodGet, "/api/collections/a%2Fb/", "/api/collections/a%2Fb"},
+		{http.MethodGet, "/x%3Fy/", "/x%3Fy"},
+		{http.MethodGet, "/api//collections/a%2Fb?x=1", "/api/collections/a%2Fb?x=1"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusPermanentRedirect, rec.Code, "%s %s", tc.method, tc.path)
+		assert.Equal(t, tc.location, rec.Header().Get(echo.HeaderLocation), "%s %s", tc.method, tc.path)
+	}
+}
+
+// Non-GET requests are rewritten in place and reach their handler. An
+// invalid payload short-circuits before the database is touched, so a 400
+// proves the route matched where the raw path would have 404ed.
+func TestNormalizePathRewritesMutations(t *testing.T) {
+	e := newRouter()
+	cases := []struct {
+		method, path string
+	}{
+		{http.MethodPost, "/api/news/"},
+		{http.MethodPost, "/api//topics"},
+		{http.MethodPut, "/api/news//1/"},
+		{http.MethodPut, "/api/topics/1/"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, bytes.NewBufferString("{not json"))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, "%s %s", tc.method, tc.path)
+	}
+}
+
+func TestNormalizePathLeavesCanonicalPathsAlone(t *testing.T) {
+	e := newRouter()
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get(echo.HeaderLocation))
+}
+
+func TestRequestIDRoundTrips(t *testing.T) {
+	e := newRouter()
+	req := httptest.NewRequest(http.MethodGet, "/healthz/live", nil)
+	req.Header.Set(echo.HeaderXRequestID, "lb-4f2a:9")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, "lb-4f2a:9", rec.Header().Get(echo.HeaderXRequestID))
+}
+
+func TestRequestIDGenerated(t *testing.T) {
+	e := newRouter()
+	for _, sent := range []string{"", "bad id\nwith newline"} {
+		req := httptest.NewRequest(http.MethodGet, "/healthz/live", nil)
+		if sent != "" {
+			req.Header.Set(echo.HeaderXRequestID, sent)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		id := rec.Header().Get(echo.HeaderXRequestID)
+		assert.Regexp(t, validRequestID, id, "sent %q", sent)
+		assert.NotEqual(t, sent, id)
+	}
+}
+
+// 5xx error bodies carry the id from the header; other errors do not
+func TestRequestIDInServerErrors(t *testing.T) {
+	saved := db
+	db = nil
+	defer func() { db = saved }()
+	e := newRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/integrity", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	var body ErrorResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.NotEmpty(t, body.RequestID)
+	assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), body.RequestID)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/unknown", nil)
+	req.Header.Set(echo.HeaderXRequestID, "abc")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "request_id")
+}
@@ -0,0 +1,269 @@
+// migrate.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// migrationLockKey identifies the advisory lock serializing schema changes
+// across every instance pointed at the same database.
+const migrationLockKey int64 = 0x6e657773 // "news"
+
+const defaultMigrationLockTimeout = 60 * time.Second
+
+// migrationFiles holds the schema as NNNN_name.up.sql and
+// NNNN_name.down.sql pairs. A new version is a new pair; applied files
+// must never be edited.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// schemaReady is set once startup has applied migrations, or found them
+// left to a -migrate-only job, and the store is usable
+var schemaReady atomic.Bool
+
+var migrationName = regexp.MustCompile(`^(\d{4})_(\w+)\.(up|down)\.sql$`)
+
+type migration struct {
+	version  int
+	name     string
+	up, down string
+}
+
+func (m migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.version, m.name)
+}
+
+// loadMigrations reads the migrations in dir, ordered by version. Every
+// version needs both an up and a down file.
+func loadMigrations(fsys fs.FS, dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*migration{}
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		m := byVersion[version]
+		if m == nil {
+			m = &migration{version: version, name: match[2]}
+			byVersion[version] = m
+		} else if m.name != match[2] {
+			return nil, fmt.Errorf("migration %04d has two names, %s and %s", version, m.name, match[2])
+		}
+		body, err := fs.ReadFile(fsys, dir+"/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if match[3] == "up" {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+
+	var migrations []migration
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %s needs both up and down files", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// runMigrations brings the schema up to the newest embedded version while
+// holding a Postgres advisory lock, so concurrently starting replicas take
+// turns instead of racing on DDL. Instances that lose the race wait up to
+// MIGRATION_LOCK_TIMEOUT. A failing migration stops the process.
+func runMigrations() {
+	withMigrationLock(func(ctx context.Context, conn *sql.Conn, migrations []migration) {
+		if err := migrateUp(ctx, conn, migrations); err != nil {
+			fatal("Migration failed", "error", err)
+		}
+	})
+}
+
+// revertMigration rolls back the newest applied migration, for -migrate-down
+func revertMigration() {
+	withMigrationLock(func(ctx context.Context, conn *sql.Conn, migrations []migration) {
+		if err := migrateDown(ctx, conn, migrations); err != nil {
+			fatal("Migration rollback failed", "error", err)
+		}
+	})
+}
+
+func withMigrationLock(fn func(ctx context.Context, conn *sql.Conn, migrations []migration)) {
+	timeout := migrationLockTimeout()
+	migrations, err := loadMigrations(migrationFiles, "migrations")
+	if err != nil {
+		fatal("Error loading migrations", "error", err)
+	}
+
+	ctx := context.Background()
+
+	// Session-level advisory locks belong to a connection, so hold one
+	// connection for the lifetime of the lock.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		fatal("Error reserving connection for migration lock", "error", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	waiting := false
+	for {
+		var acquired bool
+		err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", migrationLockKey).Scan(&acquired)
+		if err != nil {
+			fatal("Error acquiring migration lock", "error", err)
+		}
+		if acquired {
+			break
+		}
+		if time.Now().After(deadline) {
+			fatal("Timed out waiting for migration lock held by another instance", "timeout", timeout.String())
+		}
+		if !waiting {
+			slog.Info("Another instance is applying migrations, waiting for lock")
+			waiting = true
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey)
+
+	fn(ctx, conn, migrations)
+}
+
+// schemaVersion creates schema_migrations if needed and returns the
+// newest version recorded in it, 0 for a new database
+func schemaVersion(ctx context.Context, conn *sql.Conn) (int, error) {
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	var version int
+	err = conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// migrateUp applies each migration newer than the recorded version in its
+// own transaction, so a failure leaves the schema at the last good version
+func migrateUp(ctx context.Context, conn *sql.Conn, migrations []migration) error {
+	current, err := schemaVersion(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+	if n := len(migrations); n > 0 && current > migrations[n-1].version {
+		slog.Warn("Database schema is newer than this build", "version", current, "build_version", migrations[n-1].version)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		err := inTx(ctx, conn, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, m.up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.version, m.name)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", m, err)
+		}
+		slog.Info("Applied migration", "migration", m.String())
+	}
+	return nil
+}
+
+// migrateDown reverts the newest applied migration
+func migrateDown(ctx context.Context, conn *sql.Conn, migrations []migration) error {
+	current, err := schemaVersion(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+	if current == 0 {
+		return fmt.Errorf("no migrations applied")
+	}
+	for _, m := range migrations {
+		if m.version != current {
+			continue
+		}
+		err := inTx(ctx, conn, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, m.down); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", m.version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", m, err)
+		}
+		slog.Info("Reverted migration", "migration", m.String())
+		return nil
+	}
+	return fmt.Errorf("version %d is not known to this build", current)
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func migrationLockTimeout() time.Duration {
+	v := os.Getenv("MIGRATION_LOCK_TIMEOUT")
+	if v == "" {
+		return defaultMigrationLockTimeout
+	}
+	timeout, err := time.ParseDuration(v)
+	if err != nil || timeout <= 0 {
+		fatalf("Invalid MIGRATION_LOCK_TIMEOUT %q: must be a positive duration", v)
+	}
+	return timeout
+}
+
+// autoMigrate reports whether the server should apply migrations at
+// startup. Deployments that run -migrate-only in an init container set
+// AUTO_MIGRATE=false on the serving pods.
+func autoMigrate() bool {
+	v := os.Getenv("AUTO_MIGRATE")
+	if v == "" {
+		return true
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		fatalf("Invalid AUTO_MIGRATE %q: must be a boolean", v)
+	}
+	return enabled
+}
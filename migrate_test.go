@@ -0,0 +1,156 @@
+// migrate_test.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Two runners started together must serialize on the advisory lock and
+// both complete without DDL errors
+func TestConcurrentMigrations(t *testing.T) {
+	requirePostgres(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runMigrations()
+		}()
+	}
+	wg.Wait()
+
+	// The lock is released once both are done
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	var acquired bool
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", migrationLockKey).Scan(&acquired)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+	conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey)
+
+	for _, table := range []string{"topics", "news", "news_archive", "schema_migrations"} {
+		var exists bool
+		err := db.QueryRow("SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists)
+		assert.NoError(t, err)
+		assert.True(t, exists, table)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles, "migrations")
+	if assert.NoError(t, err) && assert.NotEmpty(t, migrations) {
+		assert.Equal(t, "0001_initial", migrations[0].String())
+		for i, m := range migrations {
+			assert.Equal(t, i+1, m.version, "versions are numbered without gaps")
+		}
+	}
+
+	file := func(body string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(body)} }
+	migrations, err = loadMigrations(fstest.MapFS{
+		"m/0002_second.up.sql":   file("up 2"),
+		"m/0002_second.down.sql": file("down 2"),
+		"m/0001_first.up.sql":    file("up 1"),
+		"m/0001_first.down.sql":  file("down 1"),
+	}, "m")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []migration{{1, "first", "up 1", "down 1"}, {2, "second", "up 2", "down 2"}}, migrations)
+	}
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"bad name":     {"m/1_first.up.sql": file("x")},
+		"missing down": {"m/0001_first.up.sql": file("x")},
+		"two names":    {"m/0001_first.up.sql": file("x"), "m/0001_other.down.sql": file("x")},
+	} {
+		_, err := loadMigrations(fsys, "m")
+		assert.Error(t, err, name)
+	}
+}
+
+// migrationSchema runs fn on a connection whose search_path is a fresh,
+// empty schema, so migrations can be applied and reverted without
+// touching the shared test tables
+func migrationSchema(t *testing.T, fn func(ctx context.Context, conn *sql.Conn)) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	schema := "migrate_" + strings.ToLower(uniqueName(t, "test"))
+	schema = strings.NewReplacer(" ", "_", "/", "_", "-", "_").Replace(schema)
+	if _, err := conn.ExecContext(ctx, "CREATE SCHEMA "+schema+"; SET search_path TO "+schema); !assert.NoError(t, err) {
+		return
+	}
+	defer conn.ExecContext(ctx, "RESET search_path; DROP SCHEMA "+schema+" CASCADE")
+	fn(ctx, conn)
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	requirePostgres(t)
+	migrationSchema(t, func(ctx context.Context, conn *sql.Conn) {
+		migrations, err := loadMigrations(migrationFiles, "migrations")
+		if !assert.NoError(t, err) {
+			return
+		}
+		latest := migrations[len(migrations)-1].version
+
+		assert.NoError(t, migrateUp(ctx, conn, migrations))
+		version, err := schemaVersion(ctx, conn)
+		assert.NoError(t, err)
+		assert.Equal(t, latest, version)
+
+		// Applying again is a no-op
+		assert.NoError(t, migrateUp(ctx, conn, migrations))
+
+		tableExists := func(table string) bool {
+			var exists bool
+			assert.NoError(t, conn.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists))
+			return exists
+		}
+		assert.True(t, tableExists("news"))
+
+		for version > 0 {
+			assert.NoError(t, migrateDown(ctx, conn, migrations))
+			version, _ = schemaVersion(ctx, conn)
+		}
+		assert.False(t, tableExists("news"))
+		assert.Error(t, migrateDown(ctx, conn, migrations))
+	})
+}
+
+// A failing migration names its version and leaves the schema at the
+// last one that succeeded
+func TestMigrateUpFailure(t *testing.T) {
+	requirePostgres(t)
+	migrationSchema(t, func(ctx context.Context, conn *sql.Conn) {
+		migrations, err := loadMigrations(migrationFiles, "migrations")
+		if !assert.NoError(t, err) {
+			return
+		}
+		latest := migrations[len(migrations)-1].version
+		broken := migration{version: latest + 1, name: "broken", up: "CREATE TABLE broken_ok (id INTEGER); SELEC 1", down: ""}
+
+		err = migrateUp(ctx, conn, append(migrations, broken))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), broken.String())
+		}
+		version, err := schemaVersion(ctx, conn)
+		assert.NoError(t, err)
+		assert.Equal(t, latest, version)
+
+		var exists bool
+		assert.NoError(t, conn.QueryRowContext(ctx, "SELECT to_regclass('broken_ok') IS NOT NULL").Scan(&exists))
+		assert.False(t, exists, "the failed migration is rolled back as a whole")
+	})
+}
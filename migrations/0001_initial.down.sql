@@ -0,0 +1,8 @@
+-- Drops everything 0001 creates, dependents first
+DROP TABLE IF EXISTS news_collections;
+DROP TABLE IF EXISTS collections;
+DROP TABLE IF EXISTS moderation_flags;
+DROP TABLE IF EXISTS moderation_terms;
+DROP TABLE IF EXISTS news_archive;
+DROP TABLE IF EXISTS news;
+DROP TABLE IF EXISTS topics;
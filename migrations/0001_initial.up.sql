@@ -0,0 +1,104 @@
+-- The schema as createTables left it. Every statement is idempotent so
+-- databases created before versioned migrations adopt it unchanged.
+
+CREATE TABLE IF NOT EXISTS topics (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL UNIQUE,
+	description TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS news (
+	id SERIAL PRIMARY KEY,
+	title VARCHAR(200) NOT NULL,
+	content TEXT NOT NULL,
+	topic_id INTEGER REFERENCES topics(id) ON DELETE CASCADE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Public UUIDs for external references. Adding the column with a volatile
+-- default backfills a distinct value for every existing row.
+ALTER TABLE topics ADD COLUMN IF NOT EXISTS uuid UUID NOT NULL DEFAULT gen_random_uuid();
+CREATE UNIQUE INDEX IF NOT EXISTS topics_uuid_key ON topics (uuid);
+ALTER TABLE news ADD COLUMN IF NOT EXISTS uuid UUID NOT NULL DEFAULT gen_random_uuid();
+CREATE UNIQUE INDEX IF NOT EXISTS news_uuid_key ON news (uuid);
+
+-- Same shape as news; id keeps the original article id so links and
+-- lookups by id keep working after the move. Archived rows keep the
+-- public UUID they had in news.
+CREATE TABLE IF NOT EXISTS news_archive (
+	id INTEGER PRIMARY KEY,
+	title VARCHAR(200) NOT NULL,
+	content TEXT NOT NULL,
+	topic_id INTEGER REFERENCES topics(id) ON DELETE CASCADE,
+	created_at TIMESTAMP,
+	updated_at TIMESTAMP,
+	archived_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+ALTER TABLE news_archive ADD COLUMN IF NOT EXISTS uuid UUID NOT NULL DEFAULT gen_random_uuid();
+CREATE UNIQUE INDEX IF NOT EXISTS news_archive_uuid_key ON news_archive (uuid);
+
+CREATE TABLE IF NOT EXISTS moderation_terms (
+	id SERIAL PRIMARY KEY,
+	term VARCHAR(100) NOT NULL UNIQUE,
+	action VARCHAR(10) NOT NULL DEFAULT 'reject' CHECK (action IN ('reject', 'flag')),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS moderation_flags (
+	id SERIAL PRIMARY KEY,
+	news_id INTEGER NOT NULL REFERENCES news(id) ON DELETE CASCADE,
+	reasons TEXT[] NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS collections (
+	id SERIAL PRIMARY KEY,
+	title VARCHAR(200) NOT NULL,
+	slug VARCHAR(200) NOT NULL UNIQUE,
+	description TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- news_id has no foreign key because members may live in news or
+-- news_archive; deleteNews removes memberships explicitly.
+CREATE TABLE IF NOT EXISTS news_collections (
+	collection_id INTEGER NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+	news_id INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+	PRIMARY KEY (collection_id, news_id)
+);
+
+-- Weighted full-text vectors: title matches rank above content matches.
+-- Only the first 100000 characters of content are indexed, since a
+-- tsvector cannot exceed 1MB and an article's opening is what ranking
+-- needs anyway.
+ALTER TABLE news ADD COLUMN IF NOT EXISTS search_vector tsvector
+	GENERATED ALWAYS AS (setweight(to_tsvector('english', title), 'A') || setweight(to_tsvector('english', left(content, 100000)), 'B')) STORED;
+ALTER TABLE news_archive ADD COLUMN IF NOT EXISTS search_vector tsvector
+	GENERATED ALWAYS AS (setweight(to_tsvector('english', title), 'A') || setweight(to_tsvector('english', left(content, 100000)), 'B')) STORED;
+
+-- Indexes for every query pattern the handlers run against the larger
+-- tables; TestQueryPlansUseIndexes keeps them in step with the queries.
+
+-- getAllNews ordering and the archiver's created_at cutoff
+CREATE INDEX IF NOT EXISTS news_created_idx ON news (created_at, id);
+-- getNewsByTopic, getNewsNeighbors and the deleteTopic guard
+CREATE INDEX IF NOT EXISTS news_topic_created_idx ON news (topic_id, created_at, id);
+-- The other ?sort= fields on news listings
+CREATE INDEX IF NOT EXISTS news_updated_idx ON news (updated_at, id);
+CREATE INDEX IF NOT EXISTS news_title_idx ON news (title, id);
+CREATE INDEX IF NOT EXISTS news_archive_created_idx ON news_archive (created_at);
+CREATE INDEX IF NOT EXISTS news_archive_topic_idx ON news_archive (topic_id);
+-- Ordered collection reads and membership cleanup on deleteNews
+CREATE INDEX IF NOT EXISTS news_collections_position_idx ON news_collections (collection_id, position);
+CREATE INDEX IF NOT EXISTS news_collections_news_idx ON news_collections (news_id);
+-- Full-text search
+CREATE INDEX IF NOT EXISTS news_search_idx ON news USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS news_archive_search_idx ON news_archive USING GIN (search_vector);
+-- Flag listing and the cascade from news
+CREATE INDEX IF NOT EXISTS moderation_flags_created_idx ON moderation_flags (created_at);
+CREATE INDEX IF NOT EXISTS moderation_flags_news_idx ON moderation_flags (news_id);
@@ -0,0 +1,5 @@
+ALTER TABLE topics
+	DROP COLUMN long_description,
+	DROP COLUMN meta_title,
+	DROP COLUMN meta_description,
+	DROP COLUMN header_image_url;
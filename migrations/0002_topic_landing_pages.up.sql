@@ -0,0 +1,13 @@
+-- Landing page copy for topics. The description column stays and holds the
+-- tagline, so clients and replicas reading description keep working; the
+-- limits match topicFieldLimits, which the handlers check first.
+ALTER TABLE topics
+	ADD COLUMN long_description TEXT NOT NULL DEFAULT '' CONSTRAINT topics_long_description_length CHECK (char_length(long_description) <= 20000),
+	ADD COLUMN meta_title VARCHAR(70) NOT NULL DEFAULT '',
+	ADD COLUMN meta_description VARCHAR(160) NOT NULL DEFAULT '',
+	ADD COLUMN header_image_url VARCHAR(2048) NOT NULL DEFAULT '';
+
+-- Descriptions too long for a tagline were body copy, so they start out
+-- as the long description too. They stay in place until edited.
+UPDATE topics SET long_description = description
+WHERE char_length(description) > 160 AND char_length(description) <= 20000;
@@ -0,0 +1,3 @@
+ALTER TABLE news_archive DROP COLUMN created_by;
+ALTER TABLE news DROP COLUMN created_by;
+DROP TABLE users;
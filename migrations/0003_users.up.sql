@@ -0,0 +1,13 @@
+-- Accounts for bearer auth. Emails are stored lower case, so the unique
+-- constraint is case-insensitive in practice; password_hash is bcrypt.
+CREATE TABLE users (
+	id SERIAL PRIMARY KEY,
+	email VARCHAR(254) NOT NULL CONSTRAINT users_email_key UNIQUE,
+	password_hash TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The user whose token created the article. Articles written before auth,
+-- or by a deleted user, have none. Archived rows keep their creator.
+ALTER TABLE news ADD COLUMN created_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE news_archive ADD COLUMN created_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
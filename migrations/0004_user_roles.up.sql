@@ -0,0 +1,4 @@
+-- What each user may do; see roleRanks. Existing and newly registered
+-- users are readers until an admin promotes them.
+ALTER TABLE users ADD COLUMN role VARCHAR(10) NOT NULL DEFAULT 'reader'
+	CONSTRAINT users_role_check CHECK (role IN ('reader', 'editor', 'admin'));
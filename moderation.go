@@ -0,0 +1,390 @@
+// moderation.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+// Moderation verdicts, ordered from most to least permissive
+const (
+	verdictAllow  = "allow"
+	verdictFlag   = "flag"
+	verdictReject = "reject"
+)
+
+const defaultModerationTimeout = 2 * time.Second
+
+// ModerationResult is a moderator's decision about a piece of content.
+// Reasons explain a flag or rejection and are shown to the author.
+type ModerationResult struct {
+	Verdict string   `json:"verdict"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Moderator inspects article text before it is stored
+type Moderator interface {
+	Moderate(ctx context.Context, title, content string) (ModerationResult, error)
+}
+
+type ModerationTerm struct {
+	ID        int       `json:"id"`
+	Term      string    `json:"term"`
+	Action    string    `json:"action"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ModerationFlag struct {
+	ID        int       `json:"id"`
+	NewsID    int       `json:"news_id"`
+	Reasons   []string  `json:"reasons"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Active moderator consulted by createNews and updateNews
+var moderator Moderator = allowAll{}
+
+// moderationWebhook is the HTTP moderator configuration resolved from
+// the environment
+type moderationWebhook struct {
+	URL      string
+	Timeout  time.Duration
+	FailOpen bool
+}
+
+// loadModerationWebhook reads MODERATION_WEBHOOK_URL and the variables
+// tuning it. ok is false when no webhook is configured.
+func loadModerationWebhook() (w moderationWebhook, ok bool) {
+	w.URL = os.Getenv("MODERATION_WEBHOOK_URL")
+	if w.URL == "" {
+		return w, false
+	}
+	w.Timeout = defaultModerationTimeout
+	if v := os.Getenv("MODERATION_WEBHOOK_TIMEOUT"); v != "" {
+		var err error
+		w.Timeout, err = time.ParseDuration(v)
+		if err != nil || w.Timeout <= 0 {
+			fatalf("Invalid MODERATION_WEBHOOK_TIMEOUT %q: must be a positive duration", v)
+		}
+	}
+	w.FailOpen = os.Getenv("MODERATION_FAIL_OPEN") == "true"
+	return w, true
+}
+
+// setupModeration installs the wordlist moderator, which needs Postgres for
+// its terms, chained with the HTTP moderator when MODERATION_WEBHOOK_URL
+// is set. MODERATION_WEBHOOK_TIMEOUT
+// bounds each call and MODERATION_FAIL_OPEN decides whether content is
+// allowed when the webhook errors (default: reject).
+func setupModeration() {
+	var chain moderatorChain
+	if db != nil {
+		chain = append(chain, wordlistModerator{load: loadModerationTerms})
+	}
+
+	if w, ok := loadModerationWebhook(); ok {
+		chain = append(chain, newHTTPModerator(w.URL, w.Timeout, w.FailOpen))
+		slog.Info("Moderation webhook enabled", "timeout", w.Timeout.String(), "fail_open", w.FailOpen)
+	}
+
+	moderator = chain
+}
+
+// allowAll is the moderator used before setupModeration runs
+type allowAll struct{}
+
+func (allowAll) Moderate(context.Context, string, string) (ModerationResult, error) {
+	return ModerationResult{Verdict: verdictAllow}, nil
+}
+
+// moderatorChain runs every moderator and keeps the strictest verdict,
+// merging the reasons of all moderators that objected.
+type moderatorChain []Moderator
+
+func (mc moderatorChain) Moderate(ctx context.Context, title, content string) (ModerationResult, error) {
+	result := ModerationResult{Verdict: verdictAllow}
+	for _, m := range mc {
+		r, err := m.Moderate(ctx, title, content)
+		if err != nil {
+			return result, err
+		}
+		if verdictRank(r.Verdict) > verdictRank(result.Verdict) {
+			result.Verdict = r.Verdict
+		}
+		if r.Verdict != verdictAllow {
+			result.Reasons = append(result.Reasons, r.Reasons...)
+		}
+	}
+	return result, nil
+}
+
+func verdictRank(v string) int {
+	switch v {
+	case verdictReject:
+		return 2
+	case verdictFlag:
+		return 1
+	}
+	return 0
+}
+
+// wordlistModerator matches banned terms case-insensitively against the
+// title and content. Terms are loaded per call so admin edits apply
+// immediately.
+type wordlistModerator struct {
+	load func(ctx context.Context) ([]ModerationTerm, error)
+}
+
+func (w wordlistModerator) Moderate(ctx context.Context, title, content string) (ModerationResult, error) {
+	terms, err := w.load(ctx)
+	if err != nil {
+		return ModerationResult{}, err
+	}
+
+	text := strings.ToLower(title + "\n" + content)
+	result := ModerationResult{Verdict: verdictAllow}
+	for _, t := range terms {
+		if !strings.Contains(text, strings.ToLower(t.Term)) {
+			continue
+		}
+		if verdictRank(t.Action) > verdictRank(result.Verdict) {
+			result.Verdict = t.Action
+		}
+		result.Reasons = append(result.Reasons, fmt.Sprintf("contains banned term %q", t.Term))
+	}
+	return result, nil
+}
+
+func loadModerationTerms(ctx context.Context) ([]ModerationTerm, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, term, action, created_at FROM moderation_terms ORDER BY term")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var terms []ModerationTerm
+	for rows.Next() {
+		var t ModerationTerm
+		if err := rows.Scan(&t.ID, &t.Term, &t.Action, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+	}
+	return terms, rows.Err()
+}
+
+// httpModerator posts {"title", "content"} to an external moderation API
+// and expects a ModerationResult back. Transport errors, timeouts and
+// non-2xx responses allow the content when failOpen is set and reject it
+// otherwise.
+type httpModerator struct {
+	url      string
+	client   *http.Client
+	failOpen bool
+}
+
+func newHTTPModerator(url string, timeout time.Duration, failOpen bool) httpModerator {
+	return httpModerator{url: url, client: newOutboundClient("moderation_webhook", outboundOptions{Timeout: timeout}), failOpen: failOpen}
+}
+
+func (h httpModerator) Moderate(ctx context.Context, title, content string) (ModerationResult, error) {
+	result, err := h.call(ctx, title, content)
+	if err == nil {
+		return result, nil
+	}
+
+	requestLog(ctx).Warn("Moderation webhook failed", "error", err, "fail_open", h.failOpen)
+	if h.failOpen {
+		return ModerationResult{Verdict: verdictAllow}, nil
+	}
+	return ModerationResult{Verdict: verdictReject, Reasons: []string{"moderation service unavailable"}}, nil
+}
+
+func (h httpModerator) call(ctx context.Context, title, content string) (ModerationResult, error) {
+	var result ModerationResult
+
+	body, err := json.Marshal(map[string]string{"title": title, "content": content})
+	if err != nil {
+		return result, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result, err
+	}
+	if verdictRank(result.Verdict) == 0 && result.Verdict != verdictAllow {
+		return result, fmt.Errorf("unknown verdict %q", result.Verdict)
+	}
+	return result, nil
+}
+
+// recordModerationFlag stores a review annotation for flagged content
+func recordModerationFlag(ctx context.Context, tx *sql.Tx, newsID int, result ModerationResult) error {
+	if result.Verdict != verdictFlag {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO moderation_flags (news_id, reasons) VALUES ($1, $2)", newsID, pq.Array(result.Reasons))
+	return err
+}
+
+// Moderation admin handlers
+func getModerationTerms(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	terms, err := loadModerationTerms(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to fetch moderation terms"})
+	}
+	return respondList(c, terms)
+}
+
+func createModerationTerm(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	term := new(ModerationTerm)
+	if err := c.Bind(term); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidPayload, Message: "Invalid request payload"})
+	}
+
+	// Validate fields
+	term.Term = strings.TrimSpace(term.Term)
+	if term.Term == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeValidationFailed, Message: "Term is required", Details: map[string]string{"term": "required"}})
+	}
+	if term.Action == "" {
+		term.Action = verdictReject
+	}
+	if term.Action != verdictReject && term.Action != verdictFlag {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeValidationFailed, Message: "Action must be reject or flag", Details: map[string]string{"action": "must be reject or flag"}})
+	}
+
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO moderation_terms (term, action)
+		VALUES ($1, $2)
+		RETURNING id, created_at
+	`, term.Term, term.Action).Scan(&term.ID, &term.CreatedAt)
+
+	if err != nil {
+		return respondError(c, err, "Moderation term", "Failed to create moderation term")
+	}
+
+	return c.JSON(http.StatusCreated, term)
+}
+
+// validTermID is validID for moderation terms, which have no UUID
+func validTermID(id string) bool {
+	return validID(id) && !isUUID(id)
+}
+
+func updateModerationTerm(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	id := c.Param("id")
+	if !validTermID(id) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidParameter, Message: "Invalid id"})
+	}
+	term := new(ModerationTerm)
+	if err := c.Bind(term); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidPayload, Message: "Invalid request payload"})
+	}
+
+	// Validate fields
+	term.Term = strings.TrimSpace(term.Term)
+	if term.Term == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeValidationFailed, Message: "Term is required", Details: map[string]string{"term": "required"}})
+	}
+	if term.Action != verdictReject && term.Action != verdictFlag {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeValidationFailed, Message: "Action must be reject or flag", Details: map[string]string{"action": "must be reject or flag"}})
+	}
+
+	err := db.QueryRowContext(ctx, `
+		UPDATE moderation_terms
+		SET term = $1, action = $2
+		WHERE id = $3
+		RETURNING id, term, action, created_at
+	`, term.Term, term.Action, id).Scan(&term.ID, &term.Term, &term.Action, &term.CreatedAt)
+
+	if err != nil {
+		return respondError(c, err, "Moderation term", "Failed to update moderation term")
+	}
+
+	return c.JSON(http.StatusOK, term)
+}
+
+func deleteModerationTerm(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	id := c.Param("id")
+	if !validTermID(id) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidParameter, Message: "Invalid id"})
+	}
+
+	res, err := db.ExecContext(ctx, "DELETE FROM moderation_terms WHERE id = $1", id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to delete moderation term"})
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Error checking delete result"})
+	}
+	if rowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Code: codeModerationTermNotFound, Message: "Moderation term not found"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Moderation term deleted successfully"})
+}
+
+func getModerationFlags(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, news_id, reasons, created_at
+		FROM moderation_flags
+		ORDER BY created_at DESC
+	`)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to fetch moderation flags"})
+	}
+	defer rows.Close()
+
+	var flags []ModerationFlag
+	for rows.Next() {
+		var flag ModerationFlag
+		err := rows.Scan(&flag.ID, &flag.NewsID, pq.Array(&flag.Reasons), &flag.CreatedAt)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Error scanning moderation flag row"})
+		}
+		flags = append(flags, flag)
+	}
+	if err := rows.Err(); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to fetch moderation flags"})
+	}
+
+	return respondList(c, flags)
+}
@@ -0,0 +1,187 @@
+// moderation_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// staticModerator returns a fixed result, for exercising handlers
+type staticModerator ModerationResult
+
+func (s staticModerator) Moderate(context.Context, string, string) (ModerationResult, error) {
+	return ModerationResult(s), nil
+}
+
+func staticTerms(terms ...ModerationTerm) func(context.Context) ([]ModerationTerm, error) {
+	return func(context.Context) ([]ModerationTerm, error) { return terms, nil }
+}
+
+func TestWordlistModerator(t *testing.T) {
+	m := wordlistModerator{load: staticTerms(
+		ModerationTerm{Term: "Forbidden", Action: verdictReject},
+		ModerationTerm{Term: "dubious", Action: verdictFlag},
+	)}
+	ctx := context.Background()
+
+	r, err := m.Moderate(ctx, "Clean title", "Clean body")
+	assert.NoError(t, err)
+	assert.Equal(t, verdictAllow, r.Verdict)
+	assert.Empty(t, r.Reasons)
+
+	r, err = m.Moderate(ctx, "A DUBIOUS claim", "body")
+	assert.NoError(t, err)
+	assert.Equal(t, verdictFlag, r.Verdict)
+	assert.Len(t, r.Reasons, 1)
+
+	r, err = m.Moderate(ctx, "dubious", "this is forbidden content")
+	assert.NoError(t, err)
+	assert.Equal(t, verdictReject, r.Verdict)
+	assert.Len(t, r.Reasons, 2)
+}
+
+func TestModeratorChainKeepsStrictestVerdict(t *testing.T) {
+	chain := moderatorChain{
+		staticModerator{Verdict: verdictFlag, Reasons: []string{"flagged"}},
+		staticModerator{Verdict: verdictAllow, Reasons: []string{"ignored"}},
+		staticModerator{Verdict: verdictReject, Reasons: []string{"rejected"}},
+	}
+	r, err := chain.Moderate(context.Background(), "t", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, verdictReject, r.Verdict)
+	assert.Equal(t, []string{"flagged", "rejected"}, r.Reasons)
+}
+
+func TestHTTPModerator(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		switch body["title"] {
+		case "slow":
+			time.Sleep(200 * time.Millisecond)
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case "spam":
+			json.NewEncoder(w).Encode(ModerationResult{Verdict: verdictReject, Reasons: []string{"spam"}})
+			return
+		}
+		json.NewEncoder(w).Encode(ModerationResult{Verdict: verdictAllow})
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	closed := newHTTPModerator(srv.URL, 50*time.Millisecond, false)
+	open := newHTTPModerator(srv.URL, 50*time.Millisecond, true)
+
+	r, err := closed.Moderate(ctx, "fine", "body")
+	assert.NoError(t, err)
+	assert.Equal(t, verdictAllow, r.Verdict)
+
+	r, err = closed.Moderate(ctx, "spam", "body")
+	assert.NoError(t, err)
+	assert.Equal(t, verdictReject, r.Verdict)
+	assert.Equal(t, []string{"spam"}, r.Reasons)
+
+	for _, title := range []string{"slow", "broken"} {
+		r, err = closed.Moderate(ctx, title, "body")
+		assert.NoError(t, err)
+		assert.Equal(t, verdictReject, r.Verdict, "fail closed on %s", title)
+
+		r, err = open.Moderate(ctx, title, "body")
+		assert.NoError(t, err)
+		assert.Equal(t, verdictAllow, r.Verdict, "fail open on %s", title)
+	}
+}
+
+// Test that rejected content never reaches the database
+func TestCreateNewsModerationRejected(t *testing.T) {
+	defer func(m Moderator) { moderator = m }(moderator)
+	moderator = staticModerator{Verdict: verdictReject, Reasons: []string{"contains banned term \"x\""}}
+
+	e := setupEcho()
+	req := httptest.NewRequest(http.MethodPost, "/api/news", bytes.NewBufferString(`{"title":"t","content":"x","topic_id":1}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	assert.NoError(t, createNews(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	var resp ErrorResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "MODERATION_REJECTED", resp.Code)
+	assert.Equal(t, []string{`contains banned term "x"`}, resp.Reasons)
+}
+
+// Test that flagged content is stored along with a review flag
+func TestCreateNewsModerationFlagged(t *testing.T) {
+	requirePostgres(t)
+	defer func(m Moderator) { moderator = m }(moderator)
+	moderator = staticModerator{Verdict: verdictFlag, Reasons: []string{"needs review"}}
+
+	var topicID int
+	err := db.QueryRow(`INSERT INTO topics (name, description) VALUES ('Moderation Topic', '') RETURNING id`).Scan(&topicID)
+	assert.NoError(t, err)
+	defer db.Exec("DELETE FROM topics WHERE id = $1", topicID)
+
+	e := setupEcho()
+	payload := `{"title":"Borderline","content":"Body","topic_id":` + strconv.Itoa(topicID) + `}`
+	req := httptest.NewRequest(http.MethodPost, "/api/news", bytes.NewBufferString(payload))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	assert.NoError(t, createNews(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var news News
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &news))
+	defer db.Exec("DELETE FROM news WHERE id = $1", news.ID)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/admin/moderation/flags", nil)
+	rec = httptest.NewRecorder()
+	assert.NoError(t, getModerationFlags(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var flags []ModerationFlag
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &flags))
+	found := false
+	for _, f := range flags {
+		if f.NewsID == news.ID {
+			found = true
+			assert.Equal(t, []string{"needs review"}, f.Reasons)
+		}
+	}
+	assert.True(t, found)
+}
+
+// Malformed term ids are rejected before the database sees them
+func TestModerationTermInvalidID(t *testing.T) {
+	e := setupEcho()
+	handlers := map[string]echo.HandlerFunc{
+		"updateModerationTerm": updateModerationTerm,
+		"deleteModerationTerm": deleteModerationTerm,
+	}
+	for name, handler := range handlers {
+		for _, id := range []string{"abc", "-1", "123e4567-e89b-12d3-a456-426614174000"} {
+			req := httptest.NewRequest(http.MethodPut, "/", bytes.NewBufferString(`{"term":"spam","action":"flag"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(id)
+
+			assert.NoError(t, handler(c), name)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, "%s(%q)", name, id)
+			assert.Contains(t, rec.Body.String(), `"code":"INVALID_PARAMETER"`, "%s(%q)", name, id)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// neighbors.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type NewsNeighbors struct {
+	Prev *NewsStub `json:"prev"`
+	Next *NewsStub `json:"next"`
+}
+
+// findNeighbor returns the closest article in the topic before or after
+// the (created_at, id) position of news. Each direction is a single
+// lookup on news_topic_created_idx. Archived articles are excluded just
+// like in the list endpoints.
+func findNeighbor(ctx context.Context, news News, after bool) (*NewsStub, error) {
+	query := `
+		SELECT id, uuid, title FROM news
+		WHERE topic_id = $1 AND (created_at, id) < ($2, $3)
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`
+	if after {
+		query = `
+			SELECT id, uuid, title FROM news
+			WHERE topic_id = $1 AND (created_at, id) > ($2, $3)
+			ORDER BY created_at, id
+			LIMIT 1
+		`
+	}
+
+	var stub NewsStub
+	err := db.QueryRowContext(ctx, query, news.TopicID, news.CreatedAt, news.ID).Scan(&stub.ID, &stub.UUID, &stub.Title)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stub, nil
+}
+
+// getNewsNeighbors returns the previous and next articles in the same
+// topic, with null at either boundary
+func getNewsNeighbors(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	id := c.Param("id")
+	if !validID(id) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidParameter, Message: "Invalid id"})
+	}
+	var news News
+
+	err := db.QueryRowContext(ctx, `
+		SELECT id, topic_id, created_at
+		FROM news
+		WHERE `+idColumn(id)+` = $1
+	`, id).Scan(&news.ID, &news.TopicID, &news.CreatedAt)
+
+	// Archived articles still have neighbours among live ones
+	if err == sql.ErrNoRows {
+		news, err = getArchivedNewsById(ctx, id)
+	}
+
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Code: codeNewsNotFound, Message: "News not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to fetch news"})
+	}
+
+	var neighbors NewsNeighbors
+	if neighbors.Prev, err = findNeighbor(ctx, news, false); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to fetch neighboring news"})
+	}
+	if neighbors.Next, err = findNeighbor(ctx, news, true); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to fetch neighboring news"})
+	}
+
+	return c.JSON(http.StatusOK, neighbors)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func fetchNeighbors(t *testing.T, e *echo.Echo, id string) (int, NewsNeighbors) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/news/:id/neighbors")
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+
+	assert.NoError(t, getNewsNeighbors(c))
+	var neighbors NewsNeighbors
+	if rec.Code == http.StatusOK {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &neighbors))
+	}
+	return rec.Code, neighbors
+}
+
+func TestNewsNeighbors(t *testing.T) {
+	requirePostgres(t)
+	e := setupEcho()
+	fc := useFakeClock(t, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	var topicID, otherID int
+	assert.NoError(t, db.QueryRow("INSERT INTO topics (name) VALUES ('Neighbors') RETURNING id").Scan(&topicID))
+	assert.NoError(t, db.QueryRow("INSERT INTO topics (name) VALUES ('Neighbors Other') RETURNING id").Scan(&otherID))
+	defer db.Exec("DELETE FROM topics WHERE id IN ($1, $2)", topicID, otherID)
+
+	// Two articles share a timestamp so the id tiebreak is exercised
+	var ids []int
+	for i, step := range []time.Duration{0, time.Minute, 0, time.Minute} {
+		fc.Advance(step)
+		var id int
+		err := db.QueryRow(`
+			INSERT INTO news (title, content, topic_id, created_at, updated_at)
+			VALUES ($1, 'Body', $2, $3, $3) RETURNING id
+		`, "Neighbor "+strconv.Itoa(i), topicID, timestamp()).Scan(&id)
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+	defer db.Exec("DELETE FROM news WHERE topic_id IN ($1, $2)", topicID, otherID)
+
+	_, err := db.Exec(`
+		INSERT INTO news (title, content, topic_id, created_at, updated_at)
+		VALUES ('Elsewhere', 'Body', $1, $2, $2)
+	`, otherID, timestamp())
+	assert.NoError(t, err)
+
+	code, n := fetchNeighbors(t, e, strconv.Itoa(ids[0]))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, n.Prev)
+	if assert.NotNil(t, n.Next) {
+		assert.Equal(t, ids[1], n.Next.ID)
+	}
+
+	code, n = fetchNeighbors(t, e, strconv.Itoa(ids[1]))
+	assert.Equal(t, http.StatusOK, code)
+	if assert.NotNil(t, n.Prev) && assert.NotNil(t, n.Next) {
+		assert.Equal(t, ids[0], n.Prev.ID)
+		assert.Equal(t, ids[2], n.Next.ID)
+		assert.Equal(t, "Neighbor 2", n.Next.Title)
+	}
+
+	code, n = fetchNeighbors(t, e, strconv.Itoa(ids[3]))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, n.Next)
+	if assert.NotNil(t, n.Prev) {
+		assert.Equal(t, ids[2], n.Prev.ID)
+	}
+
+	// Archived articles drop out of the chain
+	_, err = db.Exec(`
+		WITH moved AS (DELETE FROM news WHERE id = $1 RETURNING id, uuid, title, content, topic_id, created_at, updated_at)
+		INSERT INTO news_archive (id, uuid, title, content, topic_id, created_at, updated_at)
+		SELECT * FROM moved
+	`, ids[1])
+	assert.NoError(t, err)
+	defer db.Exec("DELETE FROM news_archive WHERE id = $1", ids[1])
+
+	code, n = fetchNeighbors(t, e, strconv.Itoa(ids[2]))
+	assert.Equal(t, http.StatusOK, code)
+	if assert.NotNil(t, n.Prev) {
+		assert.Equal(t, ids[0], n.Prev.ID)
+	}
+
+	code, _ = fetchNeighbors(t, e, "999999999")
+	assert.Equal(t, http.StatusNotFound, code)
+}
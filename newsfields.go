@@ -0,0 +1,134 @@
+// newsfields.go
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// newsField describes one field selectable with ?fields=: its column on
+// the listing source n and a fresh scan destination for it
+type newsField struct {
+	column  string
+	newDest func() interface{}
+}
+
+var newsFields = map[string]newsField{
+	"id":         {"n.id", func() interface{} { return new(int) }},
+	"uuid":       {"n.uuid", func() interface{} { return new(string) }},
+	"title":      {"n.title", func() interface{} { return new(string) }},
+	"content":    {"n.content", func() interface{} { return new(string) }},
+	"topic_id":   {"n.topic_id", func() interface{} { return new(*int) }},
+	"created_at": {"n.created_at", func() interface{} { return new(time.Time) }},
+	"updated_at": {"n.updated_at", func() interface{} { return new(time.Time) }},
+	"created_by": {"n.created_by", func() interface{} { return new(*int) }},
+	"archived":   {"n.archived", func() interface{} { return new(bool) }},
+}
+
+// parseFields reads ?fields=, a comma separated list of news fields. A nil
+// result means the full article shape. The error message is safe to
+// return to clients.
+func parseFields(c echo.Context) ([]string, error) {
+	fields, err := queryList(c, "fields", len(newsFields))
+	if err != nil || fields == nil {
+		return nil, err
+	}
+
+	for _, name := range fields {
+		if _, ok := newsFields[name]; !ok {
+			allowed := make([]string, 0, len(newsFields))
+			for f := range newsFields {
+				allowed = append(allowed, f)
+			}
+			sort.Strings(allowed)
+			return nil, errors.New("Fields must be drawn from " + strings.Join(allowed, ", "))
+		}
+	}
+	return fields, nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// newsSelect decides which columns a news query fetches and how each row
+// is turned into a response item
+type newsSelect struct {
+	fields       []string // nil for the full News shape
+	includeTopic bool
+	cursor       bool // sparse rows also fetch created_at and id for next_cursor
+}
+
+// columns is the SELECT list over the listing source n
+func (s newsSelect) columns() string {
+	var cols []string
+	if s.fields == nil {
+		cols = []string{"n.id", "n.uuid", "n.title", "n.content", "n.topic_id", "n.created_at", "n.updated_at", "n.created_by", "n.archived"}
+	} else {
+		for _, name := range s.fields {
+			cols = append(cols, newsFields[name].column)
+		}
+		if s.cursor {
+			cols = append(cols, "n.created_at", "n.id")
+		}
+	}
+	sel := strings.Join(cols, ", ")
+	if s.includeTopic {
+		sel += topicColumns
+	}
+	return sel
+}
+
+// join is appended to the FROM item when the topic is embedded
+func (s newsSelect) join() string {
+	if s.includeTopic {
+		return topicJoin
+	}
+	return ""
+}
+
+// scan reads one row, returning the response item and the row's position
+// for cursors
+func (s newsSelect) scan(row rowScanner) (interface{}, newsCursor, error) {
+	var topic topicScan
+	var pos newsCursor
+
+	if s.fields == nil {
+		var news News
+		dest := []interface{}{&news.ID, &news.UUID, &news.Title, &news.Content, &news.TopicID, &news.CreatedAt, &news.UpdatedAt, &news.CreatedBy, &news.Archived}
+		if s.includeTopic {
+			dest = append(dest, topic.dest()...)
+		}
+		if err := row.Scan(dest...); err != nil {
+			return nil, pos, err
+		}
+		news.Topic = topic.topic()
+		return news, newsCursor{CreatedAt: news.CreatedAt, ID: news.ID}, nil
+	}
+
+	item := make(map[string]interface{}, len(s.fields)+1)
+	var dest []interface{}
+	for _, name := range s.fields {
+		d := newsFields[name].newDest()
+		item[name] = d
+		dest = append(dest, d)
+	}
+	if s.cursor {
+		dest = append(dest, &pos.CreatedAt, &pos.ID)
+	}
+	if s.includeTopic {
+		dest = append(dest, topic.dest()...)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, pos, err
+	}
+	if s.includeTopic {
+		item["topic"] = topic.topic()
+	}
+	return item, pos, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFields(t *testing.T) {
+	e := setupEcho()
+
+	parse := func(query string) ([]string, error) {
+		req := httptest.NewRequest(http.MethodGet, "/api/news?"+query, nil)
+		return parseFields(e.NewContext(req, httptest.NewRecorder()))
+	}
+
+	fields, err := parse("")
+	assert.NoError(t, err)
+	assert.Nil(t, fields)
+
+	fields, err = parse("fields=id,%20title,id,created_at")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "title", "created_at"}, fields)
+
+	_, err = parse("fields=id,password")
+	assert.EqualError(t, err, "Fields must be drawn from archived, content, created_at, created_by, id, title, topic_id, updated_at, uuid")
+}
+
+func TestNewsSelectColumns(t *testing.T) {
+	full := newsSelect{}
+	assert.Equal(t, "n.id, n.uuid, n.title, n.content, n.topic_id, n.created_at, n.updated_at, n.created_by, n.archived", full.columns())
+
+	sparse := newsSelect{fields: []string{"title"}}
+	assert.Equal(t, "n.title", sparse.columns())
+
+	sparse.cursor = true
+	assert.Equal(t, "n.title, n.created_at, n.id", sparse.columns())
+
+	sparse.includeTopic = true
+	assert.Equal(t, "n.title, n.created_at, n.id"+topicColumns, sparse.columns())
+	assert.Equal(t, topicJoin, sparse.join())
+}
+
+func TestSparseFieldsets(t *testing.T) {
+	requirePostgres(t)
+	e := setupEcho()
+
+	var topicID, newsID int
+	assert.NoError(t, db.QueryRow("INSERT INTO topics (name) VALUES ('Fields') RETURNING id").Scan(&topicID))
+	defer db.Exec("DELETE FROM topics WHERE id = $1", topicID)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, db.QueryRow(`
+			INSERT INTO news (title, content, topic_id, created_at, updated_at)
+			VALUES ($1, 'Body', $2, $3, $3) RETURNING id
+		`, "Fields "+strconv.Itoa(i), topicID, timestamp()).Scan(&newsID))
+	}
+	defer db.Exec("DELETE FROM news WHERE topic_id = $1", topicID)
+
+	// List: only the requested keys, and the cursor still works
+	req := httptest.NewRequest(http.MethodGet, "/?fields=title&limit=2", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("topic_id")
+	c.SetParamValues(strconv.Itoa(topicID))
+	assert.NoError(t, getNewsByTopic(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("X-Next-Cursor"))
+
+	var items []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &items))
+	assert.Equal(t, 2, len(items))
+	for _, item := range items {
+		assert.Equal(t, 1, len(item))
+		assert.Contains(t, item, "title")
+	}
+
+	// Detail, combined with include=topic
+	req = httptest.NewRequest(http.MethodGet, "/?fields=id,topic_id&include=topic", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(strconv.Itoa(newsID))
+	assert.NoError(t, getNewsById(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var item map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &item))
+	assert.Equal(t, 3, len(item))
+	assert.EqualValues(t, newsID, item["id"])
+	assert.EqualValues(t, topicID, item["topic_id"])
+	assert.Contains(t, item, "topic")
+
+	// Unknown fields are rejected
+	req = httptest.NewRequest(http.MethodGet, "/?fields=secret", nil)
+	rec = httptest.NewRecorder()
+	assert.NoError(t, getAllNews(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Fields must be drawn from")
+}
@@ -0,0 +1,162 @@
+// newsquery.go
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+// NewsQuery holds the query parameters shared by the news listings
+type NewsQuery struct {
+	Page            Page
+	Sort            Sort
+	Dates           DateRange
+	Search          string
+	TopicIDs        []int
+	IncludeArchived bool
+	IncludeTopic    bool
+
+	// Topic scopes the listing to one topic by id or uuid. It comes from
+	// the path of /api/news/topic/:topic_id, not the query string.
+	Topic string
+}
+
+// maxTopicIDs caps ?topic_ids= so the ANY array stays small
+const maxTopicIDs = 50
+
+// Row sources for listings; the archived column marks where a row came from
+const (
+	liveNewsSource = `(SELECT id, uuid, title, content, topic_id, created_at, updated_at, created_by, search_vector, FALSE AS archived FROM news) n`
+	allNewsSource  = `(
+		SELECT id, uuid, title, content, topic_id, created_at, updated_at, created_by, search_vector, FALSE AS archived FROM news
+		UNION ALL
+		SELECT id, uuid, title, content, topic_id, created_at, updated_at, created_by, search_vector, TRUE AS archived FROM news_archive
+	) n`
+)
+
+// parseNewsQuery reads ?limit=, ?offset=, ?cursor=, ?sort=, ?order=,
+// ?from=, ?to=, ?q=, ?topic_ids=, ?include_archived= and ?include=. The error message is safe to
+// return to clients.
+func parseNewsQuery(c echo.Context) (NewsQuery, error) {
+	var q NewsQuery
+	var err error
+
+	if q.Page, err = parsePage(c); err != nil {
+		return q, err
+	}
+	if q.Sort, err = parseSort(c, newsSortColumns, defaultNewsSort); err != nil {
+		return q, err
+	}
+	// Cursors encode a (created_at, id) position, so they only work with
+	// the default ordering
+	if q.Page.After != nil && !q.keyset() {
+		return q, errors.New("Cursor cannot be combined with a custom sort")
+	}
+	if q.Dates, err = parseDateRange(c); err != nil {
+		return q, err
+	}
+
+	q.Search = strings.TrimSpace(c.QueryParam("q"))
+	if q.TopicIDs, err = queryInts(c, "topic_ids", maxTopicIDs); err != nil {
+		return q, err
+	}
+
+	if v := c.QueryParam("include_archived"); v != "" {
+		if q.IncludeArchived, err = strconv.ParseBool(v); err != nil {
+			return q, errors.New("Invalid include_archived: must be true or false")
+		}
+	}
+	if q.IncludeTopic, err = parseInclude(c); err != nil {
+		return q, err
+	}
+
+	return q, nil
+}
+
+// keyset reports whether next cursors can be issued for this ordering
+func (q NewsQuery) keyset() bool {
+	return q.Sort == defaultNewsSort
+}
+
+// source is the FROM item for the listing, aliased n
+func (q NewsQuery) source() string {
+	if q.IncludeArchived {
+		return allNewsSource
+	}
+	return liveNewsSource
+}
+
+// filter adds the query's conditions on n to w
+func (q NewsQuery) filter(w *where) {
+	if !q.Dates.From.IsZero() {
+		w.add("n.created_at >= ?", q.Dates.From)
+	}
+	if !q.Dates.Until.IsZero() {
+		w.add("n.created_at < ?", q.Dates.Until)
+	}
+	if q.Search != "" {
+		pattern := "%" + escapeLike(q.Search) + "%"
+		w.add(`(n.title ILIKE ? ESCAPE '\' OR n.content ILIKE ? ESCAPE '\')`, pattern, pattern)
+	}
+	if q.TopicIDs != nil {
+		w.add("n.topic_id = ANY(?)", pq.Array(q.TopicIDs))
+	}
+}
+
+// escapeLike makes s match literally inside a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// parseInclude reads ?include=, a comma separated list of related objects
+// to embed. topic is the only one so far.
+func parseInclude(c echo.Context) (topic bool, err error) {
+	names, err := queryList(c, "include", 1)
+	if err != nil {
+		return false, err
+	}
+	for _, name := range names {
+		switch name {
+		case "topic":
+			topic = true
+		default:
+			return false, errors.New("Unknown include " + strconv.Quote(name) + ": must be topic")
+		}
+	}
+	return topic, nil
+}
+
+// Joined onto a listing source n when ?include=topic is given
+const (
+	topicJoin    = " LEFT JOIN topics t ON t.id = n.topic_id"
+	topicColumns = ", t.id, t.uuid, t.name, t.description, t.created_at, t.updated_at"
+)
+
+// topicScan receives topicColumns, which are all NULL when the article
+// has no topic
+type topicScan struct {
+	id               *int
+	uuid, name, desc *string
+	created, updated *time.Time
+}
+
+func (s *topicScan) dest() []interface{} {
+	return []interface{}{&s.id, &s.uuid, &s.name, &s.desc, &s.created, &s.updated}
+}
+
+// topic returns the scanned topic, or nil if none was joined
+func (s *topicScan) topic() *TopicSummary {
+	if s.id == nil {
+		return nil
+	}
+	t := Topic{ID: *s.id, UUID: *s.uuid, Name: *s.name, CreatedAt: *s.created, UpdatedAt: *s.updated}
+	if s.desc != nil {
+		t.Tagline = *s.desc
+	}
+	return t.summary()
+}
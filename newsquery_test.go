@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscapeLike(t *testing.T) {
+	cases := map[string]string{
+		"plain":      "plain",
+		"100%":       `100\%`,
+		"snake_case": `snake\_case`,
+		`back\slash`: `back\\slash`,
+		`\%_`:        `\\\%\_`,
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, escapeLike(in), "escapeLike(%q)", in)
+	}
+}
+
+func TestParseNewsQuery(t *testing.T) {
+	e := setupEcho()
+
+	parse := func(query string) (NewsQuery, error) {
+		req := httptest.NewRequest(http.MethodGet, "/api/news?"+query, nil)
+		return parseNewsQuery(e.NewContext(req, httptest.NewRecorder()))
+	}
+
+	q, err := parse("q=%20%20&include_archived=true")
+	assert.NoError(t, err)
+	assert.Empty(t, q.Search)
+	assert.True(t, q.IncludeArchived)
+	assert.Equal(t, allNewsSource, q.source())
+
+	q, err = parse("q=" + url.QueryEscape(" 50% off "))
+	assert.NoError(t, err)
+	assert.Equal(t, "50% off", q.Search)
+	assert.Equal(t, liveNewsSource, q.source())
+
+	_, err = parse("include_archived=sometimes")
+	assert.Error(t, err)
+
+	q, err = parse("topic_ids=3,1&topic_ids=3")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 1}, q.TopicIDs)
+
+	_, err = parse("topic_ids[]=1")
+	assert.Error(t, err)
+}
+
+func TestNewsSearch(t *testing.T) {
+	requirePostgres(t)
+	e := setupEcho()
+
+	var topicID, otherID int
+	assert.NoError(t, db.QueryRow("INSERT INTO topics (name) VALUES ('Search') RETURNING id").Scan(&topicID))
+	assert.NoError(t, db.QueryRow("INSERT INTO topics (name) VALUES ('Search Other') RETURNING id").Scan(&otherID))
+	defer db.Exec("DELETE FROM topics WHERE id IN ($1, $2)", topicID, otherID)
+
+	articles := []struct {
+		title, content string
+		topic          int
+	}{
+		{"Prices up 100% in a year", "Inflation report", topicID},
+		{"Prices up 1000 in a year", "Inflation report", topicID},
+		{"snake_case naming", "Style guide", topicID},
+		{"snakeXcase naming", "Style guide", topicID},
+		{"Weather", "Mentions INFLATION in the body", otherID},
+	}
+	for _, a := range articles {
+		_, err := db.Exec(`
+			INSERT INTO news (title, content, topic_id, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $4)
+		`, a.title, a.content, a.topic, timestamp())
+		assert.NoError(t, err)
+	}
+	defer db.Exec("DELETE FROM news WHERE topic_id IN ($1, $2)", topicID, otherID)
+
+	_, err := db.Exec(`
+		INSERT INTO news_archive (id, title, content, topic_id, created_at, updated_at)
+		VALUES (999999001, 'Archived inflation story', 'Old', $1, $2, $2)
+	`, topicID, timestamp())
+	assert.NoError(t, err)
+	defer db.Exec("DELETE FROM news_archive WHERE id = 999999001")
+
+	search := func(query string) []string {
+		req := httptest.NewRequest(http.MethodGet, "/api/news?"+query, nil)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, getAllNews(e.NewContext(req, rec)))
+		assert.Equal(t, http.StatusOK, rec.Code, query)
+
+		var newsList []News
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &newsList))
+		var titles []string
+		for _, news := range newsList {
+			titles = append(titles, news.Title)
+		}
+		return titles
+	}
+
+	assert.Equal(t, []string{"Prices up 100% in a year"}, search("q="+url.QueryEscape("100%")))
+	assert.Equal(t, []string{"snake_case naming"}, search("q="+url.QueryEscape("snake_case")))
+	assert.ElementsMatch(t, []string{"Weather", "Prices up 1000 in a year", "Prices up 100% in a year"}, search("q=inflation"))
+	assert.Equal(t, 4, len(search("q=inflation&include_archived=true")))
+	assert.Equal(t, []string{"Weather"}, search("q=inflation&topic_ids="+strconv.Itoa(otherID)))
+
+	// Search composes with the topic filter
+	req := httptest.NewRequest(http.MethodGet, "/?q=inflation", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("topic_id")
+	c.SetParamValues(strconv.Itoa(otherID))
+	assert.NoError(t, getNewsByTopic(c))
+	assert.Equal(t, "1", rec.Header().Get("X-Total-Count"))
+	assert.Contains(t, rec.Body.String(), `"title":"Weather"`)
+}
+
+func TestParseInclude(t *testing.T) {
+	e := setupEcho()
+	cases := map[string]bool{"": false, "include=topic": true, "include=topic,%20topic": true}
+	for query, want := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/news?"+query, nil)
+		got, err := parseInclude(e.NewContext(req, httptest.NewRecorder()))
+		assert.NoError(t, err, query)
+		assert.Equal(t, want, got, query)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/news?include=author", nil)
+	_, err := parseInclude(e.NewContext(req, httptest.NewRecorder()))
+	assert.EqualError(t, err, `Unknown include "author": must be topic`)
+}
+
+func TestNewsIncludeTopic(t *testing.T) {
+	requirePostgres(t)
+	e := setupEcho()
+
+	var topicID, newsID int
+	assert.NoError(t, db.QueryRow("INSERT INTO topics (name, description) VALUES ('Include', 'Embedded') RETURNING id").Scan(&topicID))
+	defer db.Exec("DELETE FROM topics WHERE id = $1", topicID)
+	assert.NoError(t, db.QueryRow(`
+		INSERT INTO news (title, content, topic_id) VALUES ('Included', 'Body', $1) RETURNING id
+	`, topicID).Scan(&newsID))
+
+	get := func(query string) string {
+		req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(strconv.Itoa(newsID))
+		assert.NoError(t, getNewsById(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+
+	assert.NotContains(t, get(""), `"topic":`)
+	body := get("include=topic")
+	assert.Contains(t, body, `"topic":{"id":`+strconv.Itoa(topicID))
+	assert.Contains(t, body, `"description":"Embedded"`)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/news?include=topic", nil)
+	rec := httptest.NewRecorder()
+	assert.NoError(t, getAllNews(e.NewContext(req, rec)))
+	var newsList []News
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &newsList))
+	for _, news := range newsList {
+		if assert.NotNil(t, news.Topic, "news %d", news.ID) {
+			assert.Equal(t, news.TopicID, news.Topic.ID)
+		}
+	}
+
+	// Archived articles resolve their topic too
+	_, err := db.Exec(`
+		WITH moved AS (DELETE FROM news WHERE id = $1 RETURNING id, uuid, title, content, topic_id, created_at, updated_at)
+		INSERT INTO news_archive (id, uuid, title, content, topic_id, created_at, updated_at)
+		SELECT * FROM moved
+	`, newsID)
+	assert.NoError(t, err)
+	body = get("include=topic")
+	assert.Contains(t, body, `"archived":true`)
+	assert.Contains(t, body, `"name":"Include"`)
+}
@@ -0,0 +1,71 @@
+// newstopic.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strconv"
+)
+
+// inlineTopicCreation lets createNews create a topic named in topic_name
+// when none exists. Deployments with a fixed taxonomy turn it off with
+// INLINE_TOPIC_CREATION=false; topic_name then only resolves existing
+// topics.
+var inlineTopicCreation = true
+
+type CreateNewsRequest struct {
+	Title     string `json:"title"`
+	Content   string `json:"content"`
+	TopicID   int    `json:"topic_id"`
+	TopicName string `json:"topic_name"`
+	// CreatedBy comes from the bearer token, never the body
+	CreatedBy *int `json:"-"`
+}
+
+func loadTopicSettings() {
+	if v := os.Getenv("INLINE_TOPIC_CREATION"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			fatalf("Invalid INLINE_TOPIC_CREATION %q: must be a boolean", v)
+		}
+		inlineTopicCreation = enabled
+	}
+}
+
+// resolveTopic finds the topic a create request refers to, by id or by
+// name, creating it by name when inline creation is enabled. The topic is
+// held FOR SHARE so it cannot be deleted before the transaction commits.
+func resolveTopic(ctx context.Context, tx *sql.Tx, body *CreateNewsRequest) (Topic, error) {
+	if body.TopicName == "" {
+		return lockTopic(ctx, tx, "id", body.TopicID)
+	}
+	if !inlineTopicCreation {
+		return lockTopic(ctx, tx, "name", body.TopicName)
+	}
+
+	// ON CONFLICT waits for any concurrent insert of the same name, so
+	// when it does nothing the row is committed and lockTopic sees it.
+	topic := Topic{Name: body.TopicName}
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO topics (name, description, created_at, updated_at)
+		VALUES ($1, '', $2, $2)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING id, uuid, created_at, updated_at
+	`, body.TopicName, timestamp()).Scan(&topic.ID, &topic.UUID, &topic.CreatedAt, &topic.UpdatedAt)
+	if err != sql.ErrNoRows {
+		return topic, err
+	}
+	return lockTopic(ctx, tx, "name", body.TopicName)
+}
+
+// lockTopic loads a topic by id or name, holding it against deletion until
+// the transaction ends. column is always a literal from resolveTopic.
+func lockTopic(ctx context.Context, tx *sql.Tx, column string, value interface{}) (Topic, error) {
+	return scanTopic(tx.QueryRowContext(ctx, `
+		SELECT `+topicSelectColumns+`
+		FROM topics
+		WHERE `+column+` = $1
+		FOR SHARE
+	`, value))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func createWithTopic(t *testing.T, e *echo.Echo, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/news", bytes.NewBufferString(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	assert.NoError(t, createNews(e.NewContext(req, rec)))
+	return rec
+}
+
+func topicCount(t *testing.T, name string) int {
+	var n int
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM topics WHERE name = $1", name).Scan(&n))
+	return n
+}
+
+func TestCreateNewsWithTopicName(t *testing.T) {
+	requirePostgres(t)
+	e := setupEcho()
+	defer db.Exec("DELETE FROM topics WHERE name LIKE 'Inline %'")
+
+	rec := createWithTopic(t, e, `{"title":"Breaking","content":"Body","topic_name":"Inline Breaking"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var first News
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &first))
+	assert.NotZero(t, first.ID)
+	if !assert.NotNil(t, first.Topic) {
+		return
+	}
+	assert.Equal(t, "Inline Breaking", first.Topic.Name)
+	assert.Equal(t, first.Topic.ID, first.TopicID)
+	assert.True(t, isUUID(first.Topic.UUID))
+
+	// The same name reuses the topic; topic_id works too
+	rec = createWithTopic(t, e, `{"title":"Follow-up","content":"Body","topic_name":"Inline Breaking"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var second News
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &second))
+	if assert.NotNil(t, second.Topic) {
+		assert.Equal(t, first.Topic.ID, second.Topic.ID)
+	}
+
+	rec = createWithTopic(t, e, `{"title":"By id","content":"Body","topic_id":`+strconv.Itoa(first.Topic.ID)+`}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"name":"Inline Breaking"`)
+	assert.Equal(t, 1, topicCount(t, "Inline Breaking"))
+
+	rec = createWithTopic(t, e, `{"title":"Missing","content":"Body","topic_id":999999999}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestCreateNewsConcurrentTopicName(t *testing.T) {
+	requirePostgres(t)
+	e := setupEcho()
+	defer db.Exec("DELETE FROM topics WHERE name = 'Inline Race'")
+
+	var wg sync.WaitGroup
+	codes := make([]int, 8)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = createWithTopic(t, e, `{"title":"Race `+strconv.Itoa(i)+`","content":"Body","topic_name":"Inline Race"}`).Code
+		}(i)
+	}
+	wg.Wait()
+
+	for _, code := range codes {
+		assert.Equal(t, http.StatusCreated, code)
+	}
+	assert.Equal(t, 1, topicCount(t, "Inline Race"))
+}
+
+func TestCreateNewsValidationLeavesNoRows(t *testing.T) {
+	requirePostgres(t)
+	e := setupEcho()
+	defer func(m Moderator) { moderator = m }(moderator)
+	defer db.Exec("DELETE FROM topics WHERE name = 'Inline Rejected'")
+
+	for _, body := range []string{
+		`{"title":"","content":"Body","topic_name":"Inline Rejected"}`,
+		`{"title":"Both","content":"Body","topic_id":1,"topic_name":"Inline Rejected"}`,
+		`{"title":"Neither","content":"Body"}`,
+	} {
+		rec := createWithTopic(t, e, body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+
+	moderator = staticModerator{Verdict: verdictReject, Reasons: []string{"rejected"}}
+	rec := createWithTopic(t, e, `{"title":"Rejected","content":"Body","topic_name":"Inline Rejected"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	assert.Equal(t, 0, topicCount(t, "Inline Rejected"))
+}
+
+func TestCreateNewsInlineTopicCreationDisabled(t *testing.T) {
+	requirePostgres(t)
+	e := setupEcho()
+	defer func(enabled bool) { inlineTopicCreation = enabled }(inlineTopicCreation)
+	inlineTopicCreation = false
+
+	var topicID int
+	assert.NoError(t, db.QueryRow("INSERT INTO topics (name) VALUES ('Inline Existing') RETURNING id").Scan(&topicID))
+	defer db.Exec("DELETE FROM topics WHERE id = $1", topicID)
+
+	rec := createWithTopic(t, e, `{"title":"Locked","content":"Body","topic_name":"Inline Missing"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, 0, topicCount(t, "Inline Missing"))
+
+	rec = createWithTopic(t, e, `{"title":"Locked","content":"Body","topic_name":"Inline Existing"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"topic_id":`+strconv.Itoa(topicID))
+}
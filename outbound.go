@@ -0,0 +1,241 @@
+// outbound.go
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Limits shared by every outbound client
+const (
+	outboundMaxRedirects        = 5
+	outboundMaxConnsPerHost     = 10
+	outboundMaxIdleConnsPerHost = 4
+	outboundDialTimeout         = 5 * time.Second
+	outboundIdleConnTimeout     = 90 * time.Second
+
+	// A destination that fails this many requests in a row is skipped
+	// for the cooldown, after which requests to it are tried again
+	breakerThreshold = 5
+	breakerCooldown  = 30 * time.Second
+)
+
+var (
+	errPrivateAddress = errors.New("destination is a private address")
+	errCircuitOpen    = errors.New("circuit open")
+)
+
+// outboundOptions tunes a client built by newOutboundClient. Timeout
+// bounds a whole request, redirects included. BlockPrivate refuses to
+// connect to loopback, link-local and private addresses, and must be set
+// whenever the URL comes from a user rather than the operator.
+type outboundOptions struct {
+	Timeout      time.Duration
+	BlockPrivate bool
+}
+
+// newOutboundClient returns the HTTP client integrations use to call out.
+// name labels its requests in the outbound stats.
+func newOutboundClient(name string, opts outboundOptions) *http.Client {
+	dialer := &net.Dialer{Timeout: outboundDialTimeout}
+	if opts.BlockPrivate {
+		// Checked on the resolved address at connect time, so neither
+		// DNS names nor redirects can reach an internal host
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+				return fmt.Errorf("%s: %w", host, errPrivateAddress)
+			}
+			return nil
+		}
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       &tls.Config{MinVersion: tls.VersionTLS12},
+		TLSHandshakeTimeout:   outboundDialTimeout,
+		ResponseHeaderTimeout: opts.Timeout,
+		MaxConnsPerHost:       outboundMaxConnsPerHost,
+		MaxIdleConnsPerHost:   outboundMaxIdleConnsPerHost,
+		IdleConnTimeout:       outboundIdleConnTimeout,
+	}
+	if opts.BlockPrivate {
+		// A proxy would make the connection on our behalf, past the check
+		transport.Proxy = nil
+	}
+
+	return &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: &outboundTransport{name: name, next: transport, stats: outbound.integration(name)},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= outboundMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", outboundMaxRedirects)
+			}
+			return nil
+		},
+	}
+}
+
+// isPrivateIP reports whether ip is loopback, link-local, private
+// (RFC 1918 and IPv6 unique local) or unspecified
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsPrivate() || ip.IsUnspecified()
+}
+
+// outboundTransport records stats for each request and trips a circuit
+// breaker per destination host
+type outboundTransport struct {
+	name     string
+	next     http.RoundTripper
+	stats    *integrationStats
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+type breaker struct {
+	failures  int
+	openUntil time.Time
+}
+
+func (t *outboundTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if !t.allow(host) {
+		t.stats.record(0, errCircuitOpen)
+		return nil, fmt.Errorf("%s %s: %w", t.name, host, errCircuitOpen)
+	}
+
+	start := clock.Now()
+	resp, err := t.next.RoundTrip(req)
+	failed := err
+	if err == nil && resp.StatusCode >= 500 {
+		failed = fmt.Errorf("status %d", resp.StatusCode)
+	}
+	t.stats.record(clock.Now().Sub(start), failed)
+	t.report(host, failed == nil)
+	return resp, err
+}
+
+// allow reports whether a request to host may go out. Once the cooldown
+// has passed the breaker lets requests through again; the next failure
+// reopens it straight away since the failure count is kept.
+func (t *outboundTransport) allow(host string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := t.breakers[host]
+	return b == nil || b.failures < breakerThreshold || !clock.Now().Before(b.openUntil)
+}
+
+func (t *outboundTransport) report(host string, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ok {
+		delete(t.breakers, host)
+		return
+	}
+	if t.breakers == nil {
+		t.breakers = map[string]*breaker{}
+	}
+	b := t.breakers[host]
+	if b == nil {
+		b = &breaker{}
+		t.breakers[host] = b
+	}
+	b.failures++
+	if b.failures >= breakerThreshold {
+		b.openUntil = clock.Now().Add(breakerCooldown)
+	}
+}
+
+// OutboundStats counts the outbound requests of one integration.
+// Rejected counts requests the circuit breaker did not send.
+type OutboundStats struct {
+	Name         string  `json:"name"`
+	Requests     int64   `json:"requests"`
+	Errors       int64   `json:"errors"`
+	Rejected     int64   `json:"rejected"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	MaxLatencyMs float64 `json:"max_latency_ms"`
+}
+
+type integrationStats struct {
+	mu                       sync.Mutex
+	stats                    OutboundStats
+	totalLatency, maxLatency time.Duration
+}
+
+func (s *integrationStats) record(latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if errors.Is(err, errCircuitOpen) {
+		s.stats.Rejected++
+		return
+	}
+	s.stats.Requests++
+	if err != nil {
+		s.stats.Errors++
+	}
+	s.totalLatency += latency
+	if latency > s.maxLatency {
+		s.maxLatency = latency
+	}
+}
+
+func (s *integrationStats) snapshot() OutboundStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := s.stats
+	if out.Requests > 0 {
+		out.AvgLatencyMs = float64(s.totalLatency) / float64(out.Requests) / float64(time.Millisecond)
+	}
+	out.MaxLatencyMs = float64(s.maxLatency) / float64(time.Millisecond)
+	return out
+}
+
+// outboundRegistry holds the stats of every integration by name
+type outboundRegistry struct {
+	mu     sync.Mutex
+	byName map[string]*integrationStats
+}
+
+var outbound = &outboundRegistry{byName: map[string]*integrationStats{}}
+
+func (r *outboundRegistry) integration(name string) *integrationStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.byName[name]
+	if s == nil {
+		s = &integrationStats{stats: OutboundStats{Name: name}}
+		r.byName[name] = s
+	}
+	return s
+}
+
+func (r *outboundRegistry) snapshot() []OutboundStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []OutboundStats{}
+	for _, s := range r.byName {
+		out = append(out, s.snapshot())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// getOutboundStats reports request counts and latency per integration
+func getOutboundStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, outbound.snapshot())
+}
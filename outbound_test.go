@@ -0,0 +1,116 @@
+// outbound_test.go
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPrivateIP(t *testing.T) {
+	private := []string{
+		"127.0.0.1", "127.1.2.3", "::1", // loopback
+		"169.254.169.254", "fe80::1", // link-local, cloud metadata included
+		"10.0.0.1", "172.16.0.1", "172.31.255.255", "192.168.1.1", // RFC 1918
+		"fd00::1", "0.0.0.0", "::",
+	}
+	for _, addr := range private {
+		assert.True(t, isPrivateIP(net.ParseIP(addr)), addr)
+	}
+	for _, addr := range []string{"8.8.8.8", "172.32.0.1", "93.184.216.34", "2606:4700::1111"} {
+		assert.False(t, isPrivateIP(net.ParseIP(addr)), addr)
+	}
+}
+
+func TestOutboundClientBlocksPrivateDestinations(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	guarded := newOutboundClient("test_guarded", outboundOptions{Timeout: time.Second, BlockPrivate: true})
+	for _, url := range []string{
+		srv.URL,
+		"http://localhost:1/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.1/", "http://172.16.0.1/", "http://192.168.0.1/",
+	} {
+		_, err := guarded.Get(url)
+		assert.ErrorIs(t, err, errPrivateAddress, url)
+	}
+
+	// Redirects are checked too, at connect time
+	public := httptest.NewServer(http.RedirectHandler(srv.URL, http.StatusFound))
+	defer public.Close()
+	_, err := guarded.Get(public.URL)
+	assert.ErrorIs(t, err, errPrivateAddress)
+
+	// Operator-configured destinations may be internal
+	resp, err := newOutboundClient("test_open", outboundOptions{Timeout: time.Second}).Get(srv.URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestOutboundClientCapsRedirects(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, srv.URL+"/again", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	_, err := newOutboundClient("test_redirects", outboundOptions{Timeout: time.Second}).Get(srv.URL)
+	assert.ErrorContains(t, err, "stopped after 5 redirects")
+}
+
+func TestOutboundCircuitBreaker(t *testing.T) {
+	fc := useFakeClock(t, time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC))
+	healthy := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	client := newOutboundClient("test_breaker", outboundOptions{Timeout: time.Second})
+	get := func() error {
+		resp, err := client.Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	for i := 0; i < breakerThreshold; i++ {
+		assert.NoError(t, get())
+	}
+	assert.True(t, errors.Is(get(), errCircuitOpen))
+
+	// After the cooldown requests go out again; a success closes it
+	fc.Advance(breakerCooldown)
+	healthy = true
+	assert.NoError(t, get())
+	assert.NoError(t, get())
+
+	stats := outbound.integration("test_breaker").snapshot()
+	assert.Equal(t, int64(breakerThreshold+2), stats.Requests)
+	assert.Equal(t, int64(breakerThreshold), stats.Errors)
+	assert.Equal(t, int64(1), stats.Rejected)
+}
+
+func TestGetOutboundStats(t *testing.T) {
+	outbound.integration("test_listed")
+
+	s := newTestServer(t)
+	var stats []OutboundStats
+	s.expect(s.do(http.MethodGet, "/api/admin/outbound", nil), http.StatusOK, &stats)
+	var names []string
+	for _, st := range stats {
+		names = append(names, st.Name)
+	}
+	assert.Contains(t, names, "test_listed")
+}
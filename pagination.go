@@ -0,0 +1,95 @@
+// pagination.go
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Page size bounds for list endpoints
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// Page selects a slice of a list, either by offset or, when After is set,
+// by keyset from the position of the last row a client saw
+type Page struct {
+	Limit  int
+	Offset int
+	After  *newsCursor
+}
+
+// newsCursor is a position in the (created_at, id) ordering of news.
+// Clients only ever see it encoded and must treat it as opaque; the
+// encoding may change.
+type newsCursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+func (nc newsCursor) encode() string {
+	raw := strconv.FormatInt(nc.CreatedAt.UnixMicro(), 10) + ":" + strconv.Itoa(nc.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeNewsCursor(s string) (*newsCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	micros, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, errors.New("malformed cursor")
+	}
+	us, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+	return &newsCursor{CreatedAt: time.UnixMicro(us).UTC(), ID: n}, nil
+}
+
+// parsePage reads ?limit= and ?offset= or ?cursor=, applying the default
+// page size when limit is absent. The error message is safe to return to
+// clients.
+func parsePage(c echo.Context) (Page, error) {
+	page := Page{Limit: defaultPageSize}
+
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return page, errors.New("Limit must be an integer between 1 and " + strconv.Itoa(maxPageSize))
+		}
+		page.Limit = n
+	}
+
+	if v := c.QueryParam("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return page, errors.New("Offset must be a non-negative integer")
+		}
+		page.Offset = n
+	}
+
+	if v := c.QueryParam("cursor"); v != "" {
+		if page.Offset != 0 {
+			return page, errors.New("Cursor and offset cannot be combined")
+		}
+		after, err := decodeNewsCursor(v)
+		if err != nil {
+			return page, errors.New("Invalid cursor")
+		}
+		page.After = after
+	}
+
+	return page, nil
+}
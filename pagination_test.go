@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePage(t *testing.T) {
+	e := setupEcho()
+	cursor := newsCursor{CreatedAt: time.Date(2024, 2, 1, 12, 30, 0, 123456000, time.UTC), ID: 42}
+	cases := []struct {
+		query string
+		want  Page
+		ok    bool
+	}{
+		{"", Page{Limit: defaultPageSize}, true},
+		{"limit=5", Page{Limit: 5}, true},
+		{"limit=100&offset=300", Page{Limit: 100, Offset: 300}, true},
+		{"offset=0", Page{Limit: defaultPageSize}, true},
+		{"limit=0", Page{}, false},
+		{"limit=101", Page{}, false},
+		{"limit=-1", Page{}, false},
+		{"limit=ten", Page{}, false},
+		{"offset=-5", Page{}, false},
+		{"offset=1.5", Page{}, false},
+		{"cursor=" + cursor.encode(), Page{Limit: defaultPageSize, After: &cursor}, true},
+		{"limit=5&cursor=" + cursor.encode(), Page{Limit: 5, After: &cursor}, true},
+		{"offset=5&cursor=" + cursor.encode(), Page{}, false},
+		{"cursor=not-a-cursor", Page{}, false},
+		{"cursor=" + base64.RawURLEncoding.EncodeToString([]byte("123")), Page{}, false},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/news?"+tc.query, nil)
+		page, err := parsePage(e.NewContext(req, httptest.NewRecorder()))
+		if tc.ok {
+			assert.NoError(t, err, tc.query)
+			assert.Equal(t, tc.want, page, tc.query)
+		} else {
+			assert.Error(t, err, tc.query)
+		}
+	}
+}
+
+func TestNewsPagination(t *testing.T) {
+	requirePostgres(t)
+	e := setupEcho()
+	fc := useFakeClock(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+
+	var topicID int
+	assert.NoError(t, db.QueryRow("INSERT INTO topics (name) VALUES ('Pagination') RETURNING id").Scan(&topicID))
+	defer db.Exec("DELETE FROM topics WHERE id = $1", topicID)
+
+	for i := 0; i < 5; i++ {
+		fc.Advance(time.Minute)
+		_, err := db.Exec(`
+			INSERT INTO news (title, content, topic_id, created_at, updated_at)
+			VALUES ($1, 'Body', $2, $3, $3)
+		`, "Page "+strconv.Itoa(i), topicID, timestamp())
+		assert.NoError(t, err)
+	}
+
+	list := func(query string) (int, []News) {
+		req := httptest.NewRequest(http.MethodGet, "/api/news/topic/"+strconv.Itoa(topicID)+"?"+query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/api/news/topic/:topic_id")
+		c.SetParamNames("topic_id")
+		c.SetParamValues(strconv.Itoa(topicID))
+		assert.NoError(t, getNewsByTopic(c))
+
+		var newsList []News
+		if rec.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &newsList))
+		}
+		return rec.Code, newsList
+	}
+
+	code, first := list("limit=2")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, first, 2) {
+		assert.Equal(t, "Page 4", first[0].Title)
+		assert.Equal(t, "Page 3", first[1].Title)
+	}
+
+	code, last := list("limit=2&offset=4")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, last, 1) {
+		assert.Equal(t, "Page 0", last[0].Title)
+	}
+
+	code, _ = list("limit=1000")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, sorted := list("sort=title&limit=2")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, sorted, 2) {
+		assert.Equal(t, "Page 0", sorted[0].Title)
+		assert.Equal(t, "Page 1", sorted[1].Title)
+	}
+
+	code, _ = list("sort=title&cursor=" + newsCursor{CreatedAt: fc.Now(), ID: 1}.encode())
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// Date ranges compose with the topic filter and pagination
+	code, ranged := list("from=2024-02-01T00:02:00Z&to=2024-02-01T00:04:00Z&limit=2&offset=1")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, ranged, 2) {
+		assert.Equal(t, "Page 2", ranged[0].Title)
+		assert.Equal(t, "Page 1", ranged[1].Title)
+	}
+
+	code, _ = list("from=soon")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// Following next cursors walks every article exactly once, in order
+	var titles []string
+	query := "limit=2"
+	for {
+		req := httptest.NewRequest(http.MethodGet, "/api/news/topic/"+strconv.Itoa(topicID)+"?"+query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("topic_id")
+		c.SetParamValues(strconv.Itoa(topicID))
+		assert.NoError(t, getNewsByTopic(c))
+		if !assert.Equal(t, http.StatusOK, rec.Code) {
+			break
+		}
+		assert.Equal(t, "5", rec.Header().Get("X-Total-Count"))
+
+		var newsList []News
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &newsList))
+		for _, news := range newsList {
+			titles = append(titles, news.Title)
+		}
+
+		next := rec.Header().Get("X-Next-Cursor")
+		if next == "" {
+			break
+		}
+		query = "limit=2&cursor=" + next
+	}
+	assert.Equal(t, []string{"Page 4", "Page 3", "Page 2", "Page 1", "Page 0"}, titles)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/news?offset=-1", nil)
+	rec := httptest.NewRecorder()
+	assert.NoError(t, getAllNews(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"message":"Offset must be a non-negative integer"`)
+}
+
+func TestNewsCursorRoundTrip(t *testing.T) {
+	cursor := newsCursor{CreatedAt: time.Date(2024, 2, 1, 12, 30, 0, 123456000, time.UTC), ID: 42}
+	decoded, err := decodeNewsCursor(cursor.encode())
+	assert.NoError(t, err)
+	assert.Equal(t, cursor, *decoded)
+}
@@ -0,0 +1,104 @@
+// params.go
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// idColumn picks the lookup column for an :id path param. Public UUIDs are
+// detected by format; anything else is matched against the integer id.
+func idColumn(param string) string {
+	if isUUID(param) {
+		return "uuid"
+	}
+	return "id"
+}
+
+// validID reports whether an :id path param is a public UUID or an
+// integer id within the range of the INTEGER id columns. Anything else
+// would only fail in the database.
+func validID(param string) bool {
+	if isUUID(param) {
+		return true
+	}
+	n, err := strconv.ParseInt(param, 10, 32)
+	return err == nil && n >= 0
+}
+
+// isUUID reports whether s is a canonical 36-character hyphenated UUID
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// queryList reads a multi-valued query param sent as name=a,b, as repeated
+// name=a&name=b, or both. Values are trimmed, blanks dropped and
+// duplicates removed keeping the first. The bracket form name[]=a is
+// rejected rather than silently ignored, as are more than max values.
+// Error messages are safe to return to clients.
+func queryList(c echo.Context, name string, max int) ([]string, error) {
+	params := c.QueryParams()
+	if _, ok := params[name+"[]"]; ok {
+		return nil, fmt.Errorf("%s[] is not supported: send %s=a,b or repeat %s", name, name, name)
+	}
+
+	var values []string
+	seen := map[string]bool{}
+	for _, raw := range params[name] {
+		for _, v := range strings.Split(raw, ",") {
+			v = strings.TrimSpace(v)
+			if v == "" || seen[v] {
+				continue
+			}
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	if len(values) > max {
+		return nil, fmt.Errorf("Too many %s values: at most %d allowed", name, max)
+	}
+	return values, nil
+}
+
+// queryInts is queryList for integer values. Every invalid value is
+// reported in the one error, not just the first.
+func queryInts(c echo.Context, name string, max int) ([]int, error) {
+	values, err := queryList(c, name, max)
+	if err != nil {
+		return nil, err
+	}
+
+	var ints []int
+	var invalid []string
+	for _, v := range values {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			invalid = append(invalid, strconv.Quote(v))
+			continue
+		}
+		ints = append(ints, n)
+	}
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("Invalid %s: %s must be integers", name, strings.Join(invalid, ", "))
+	}
+	return ints, nil
+}
@@ -0,0 +1,100 @@
+// params_test.go
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdColumn(t *testing.T) {
+	cases := map[string]string{
+		"1":                                    "id",
+		"42":                                   "id",
+		"abc":                                  "id",
+		"3f2b8c9e-4a1d-4c6e-9b7a-2d5e8f1a3c4b": "uuid",
+		"3F2B8C9E-4A1D-4C6E-9B7A-2D5E8F1A3C4B": "uuid",
+		"3f2b8c9e4a1d4c6e9b7a2d5e8f1a3c4b":     "id",
+		"3f2b8c9e-4a1d-4c6e-9b7a-2d5e8f1a3c4g": "id",
+		"3f2b8c9e-4a1d-4c6e-9b7a_2d5e8f1a3c4b": "id",
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, idColumn(in), "idColumn(%q)", in)
+	}
+}
+
+func TestValidID(t *testing.T) {
+	cases := map[string]bool{
+		"1":                                    true,
+		"0":                                    true,
+		"2147483647":                           true,
+		"3f2b8c9e-4a1d-4c6e-9b7a-2d5e8f1a3c4b": true,
+		"":                                     false,
+		"abc":                                  false,
+		"-1":                                   false,
+		"1.5":                                  false,
+		"2147483648":                           false,
+		"99999999999999999999":                 false,
+		"3f2b8c9e-4a1d-4c6e-9b7a-2d5e8f1a3c4g": false,
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, validID(in), "validID(%q)", in)
+	}
+}
+
+func TestQueryList(t *testing.T) {
+	e := setupEcho()
+
+	cases := []struct {
+		query string
+		want  []string
+		err   string
+	}{
+		{query: "", want: nil},
+		{query: "ids=1,2,3", want: []string{"1", "2", "3"}},
+		{query: "ids=1&ids=2&ids=3", want: []string{"1", "2", "3"}},
+		{query: "ids=1,2&ids=3", want: []string{"1", "2", "3"}},
+		{query: "ids=%201%20,,2", want: []string{"1", "2"}},
+		{query: "ids=2,1,2&ids=1", want: []string{"2", "1"}},
+		{query: "ids[]=1", err: "ids[] is not supported: send ids=a,b or repeat ids"},
+		{query: "ids=1&ids[]=2", err: "ids[] is not supported: send ids=a,b or repeat ids"},
+		{query: "ids=1,2,3,4", err: "Too many ids values: at most 3 allowed"},
+		{query: "ids=1,1,1,1,2", want: []string{"1", "2"}},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/?"+tc.query, nil)
+		got, err := queryList(e.NewContext(req, httptest.NewRecorder()), "ids", 3)
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err, tc.query)
+			continue
+		}
+		assert.NoError(t, err, tc.query)
+		assert.Equal(t, tc.want, got, tc.query)
+	}
+}
+
+func TestQueryInts(t *testing.T) {
+	e := setupEcho()
+
+	cases := []struct {
+		query string
+		want  []int
+		err   string
+	}{
+		{query: "ids=3,1&ids=3", want: []int{3, 1}},
+		{query: "ids=1,x&ids=2.5", err: `Invalid ids: "x", "2.5" must be integers`},
+		{query: "ids[]=1", err: "ids[] is not supported: send ids=a,b or repeat ids"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/?"+tc.query, nil)
+		got, err := queryInts(e.NewContext(req, httptest.NewRecorder()), "ids", 10)
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err, tc.query)
+			continue
+		}
+		assert.NoError(t, err, tc.query)
+		assert.Equal(t, tc.want, got, tc.query)
+	}
+}
@@ -0,0 +1,138 @@
+// patch.go
+package main
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// PatchNewsRequest carries the fields a PATCH changes; nil means keep
+type PatchNewsRequest struct {
+	Title   *string `json:"title"`
+	Content *string `json:"content"`
+	TopicID *int    `json:"topic_id"`
+}
+
+// PatchTopicRequest carries the fields a topic PATCH changes. An empty
+// value clears a field; an omitted one is kept. Description is the legacy
+// name for tagline, used when tagline is omitted.
+type PatchTopicRequest struct {
+	Name            *string `json:"name"`
+	Description     *string `json:"description"`
+	Tagline         *string `json:"tagline"`
+	LongDescription *string `json:"long_description"`
+	MetaTitle       *string `json:"meta_title"`
+	MetaDescription *string `json:"meta_description"`
+	HeaderImageURL  *string `json:"header_image_url"`
+}
+
+// patchNews updates only the fields present in the body. updated_at moves
+// only when a value actually differs from what is stored.
+func patchNews(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	id := c.Param("id")
+	if !validID(id) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidParameter, Message: "Invalid id"})
+	}
+	body := new(PatchNewsRequest)
+	if err := c.Bind(body); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidPayload, Message: "Invalid request payload"})
+	}
+
+	// Validate the fields that were sent
+	if body.Title == nil && body.Content == nil && body.TopicID == nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeValidationFailed, Message: "At least one of title, content or topic_id is required"})
+	}
+	details := map[string]string{}
+	if body.Title != nil && *body.Title == "" {
+		details["title"] = "cannot be empty"
+	}
+	if body.Content != nil && *body.Content == "" {
+		details["content"] = "cannot be empty"
+	}
+	if len(details) > 0 {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeValidationFailed, Message: "Title and content cannot be empty", Details: details})
+	}
+	if body.Content != nil && len(*body.Content) > maxContentBytes {
+		return c.JSON(http.StatusUnprocessableEntity, ErrorResponse{Code: codeContentTooLarge, Message: contentTooLargeMessage()})
+	}
+
+	// Run content moderation on new text; unchanged text was checked when
+	// it was written
+	var verdict ModerationResult
+	if body.Title != nil || body.Content != nil {
+		var title, content string
+		if body.Title != nil {
+			title = *body.Title
+		}
+		if body.Content != nil {
+			content = *body.Content
+		}
+		var err error
+		verdict, err = moderator.Moderate(c.Request().Context(), title, content)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Error moderating content"})
+		}
+		if verdict.Verdict == verdictReject {
+			return c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+				Code:    codeModerationRejected,
+				Message: "Content was rejected by moderation",
+				Reasons: verdict.Reasons,
+			})
+		}
+	}
+
+	news, err := store.UpdateNews(ctx, id, NewsChange{Title: body.Title, Content: body.Content, TopicID: body.TopicID}, verdict)
+	if err != nil {
+		return respondNewsError(c, err, "Failed to update news")
+	}
+
+	return c.JSON(http.StatusOK, news)
+}
+
+// patchTopic updates only the fields present in the body, moving
+// updated_at only when a value actually differs
+func patchTopic(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	id := c.Param("id")
+	if !validID(id) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidParameter, Message: "Invalid id"})
+	}
+	body := new(PatchTopicRequest)
+	if err := c.Bind(body); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidPayload, Message: "Invalid request payload"})
+	}
+
+	if body.Tagline == nil {
+		body.Tagline = body.Description
+	}
+	change := TopicChange{
+		Name:            body.Name,
+		Tagline:         body.Tagline,
+		LongDescription: body.LongDescription,
+		MetaTitle:       body.MetaTitle,
+		MetaDescription: body.MetaDescription,
+		HeaderImageURL:  body.HeaderImageURL,
+	}
+
+	// Validate the fields that were sent
+	if change == (TopicChange{}) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeValidationFailed, Message: "At least one of name, tagline, long_description, meta_title, meta_description or header_image_url is required"})
+	}
+	if body.Name != nil && *body.Name == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeValidationFailed, Message: "Topic name cannot be empty", Details: map[string]string{"name": "cannot be empty"}})
+	}
+	if details := topicFieldProblems(change.fields()); len(details) > 0 {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeValidationFailed, Message: topicFieldsMessage(details), Details: details})
+	}
+
+	topic, err := store.UpdateTopic(ctx, id, change)
+	if err != nil {
+		return respondError(c, err, "Topic", "Failed to update topic")
+	}
+
+	return c.JSON(http.StatusOK, topic)
+}
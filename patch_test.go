@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatchNews(t *testing.T) {
+	requirePostgres(t)
+	e := setupEcho()
+
+	var topicID, otherID, newsID int
+	assert.NoError(t, db.QueryRow("INSERT INTO topics (name) VALUES ('Patch') RETURNING id").Scan(&topicID))
+	assert.NoError(t, db.QueryRow("INSERT INTO topics (name) VALUES ('Patch Other') RETURNING id").Scan(&otherID))
+	defer db.Exec("DELETE FROM topics WHERE id IN ($1, $2)", topicID, otherID)
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, db.QueryRow(`
+		INSERT INTO news (title, content, topic_id, created_at, updated_at)
+		VALUES ('Original', 'Body', $1, $2, $2) RETURNING id
+	`, topicID, created).Scan(&newsID))
+
+	patch := func(payload string) (*httptest.ResponseRecorder, News) {
+		req := httptest.NewRequest(http.MethodPatch, "/", bytes.NewBufferString(payload))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(strconv.Itoa(newsID))
+		assert.NoError(t, patchNews(c))
+
+		var news News
+		if rec.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &news))
+		}
+		return rec, news
+	}
+
+	// Only the title changes
+	rec, news := patch(`{"title":"Renamed"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Renamed", news.Title)
+	assert.Equal(t, "Body", news.Content)
+	assert.Equal(t, topicID, news.TopicID)
+	assert.True(t, news.UpdatedAt.After(created))
+
+	// Sending the stored values leaves updated_at alone
+	updated := news.UpdatedAt
+	rec, news = patch(`{"title":"Renamed","content":"Body"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, news.UpdatedAt.Equal(updated))
+
+	rec, news = patch(`{"topic_id":` + strconv.Itoa(otherID) + `}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, otherID, news.TopicID)
+	assert.Equal(t, "Renamed", news.Title)
+
+	// Validation
+	rec, _ = patch(`{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec, _ = patch(`{"content":""}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"VALIDATION_FAILED"`)
+	assert.Contains(t, rec.Body.String(), `"details":{"content":"cannot be empty"}`)
+	rec, _ = patch(`{"topic_id":999999}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Topic does not exist")
+	assert.Contains(t, rec.Body.String(), `"code":"INVALID_REFERENCE"`)
+	assert.Contains(t, rec.Body.String(), `"details":{"topic_id":"does not exist"}`)
+
+	// Missing articles are reported as such
+	db.Exec("DELETE FROM news WHERE id = $1", newsID)
+	rec, _ = patch(`{"title":"Gone"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestPatchTopic(t *testing.T) {
+	requirePostgres(t)
+	e := setupEcho()
+
+	var topicID, otherID int
+	assert.NoError(t, db.QueryRow("INSERT INTO topics (name, description) VALUES ('Patch Topic', 'Keep me') RETURNING id").Scan(&topicID))
+	assert.NoError(t, db.QueryRow("INSERT INTO topics (name, description) VALUES ('Patch Taken', '') RETURNING id").Scan(&otherID))
+	defer db.Exec("DELETE FROM topics WHERE id IN ($1, $2)", topicID, otherID)
+
+	patch := func(id int, payload string) (*httptest.ResponseRecorder, Topic) {
+		req := httptest.NewRequest(http.MethodPatch, "/", bytes.NewBufferString(payload))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(strconv.Itoa(id))
+		assert.NoError(t, patchTopic(c))
+
+		var topic Topic
+		if rec.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &topic))
+		}
+		return rec, topic
+	}
+
+	// Omitted description is kept
+	rec, topic := patch(topicID, `{"name":"Patch Renamed"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Patch Renamed", topic.Name)
+	assert.Equal(t, "Keep me", topic.Description)
+
+	// An empty description clears it
+	rec, topic = patch(topicID, `{"description":""}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Patch Renamed", topic.Name)
+	assert.Empty(t, topic.Description)
+
+	rec, _ = patch(topicID, `{"name":""}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec, _ = patch(topicID, `{"name":"Patch Taken"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec, _ = patch(999999, `{"description":"Nobody"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
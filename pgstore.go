@@ -0,0 +1,337 @@
+// pgstore.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// pgStore is the Postgres Store
+type pgStore struct {
+	db *sql.DB
+}
+
+func newPGStore(db *sql.DB) *pgStore {
+	return &pgStore{db: db}
+}
+
+func (s *pgStore) GetNews(ctx context.Context, id string, fields []string, includeTopic bool) (interface{}, error) {
+	sel := newsSelect{fields: fields, includeTopic: includeTopic}
+
+	// Articles moved out of the hot table are found in the archive half
+	news, _, err := sel.scan(s.db.QueryRowContext(ctx, `
+		SELECT `+sel.columns()+`
+		FROM `+allNewsSource+sel.join()+`
+		WHERE n.`+idColumn(id)+` = $1
+	`, id))
+	if err != nil {
+		return nil, storeError(err)
+	}
+	return news, nil
+}
+
+// ListNews runs q's filters for both the total count and the page, so the
+// two always agree. Under the default ordering a next cursor is returned
+// whenever more rows follow the page.
+func (s *pgStore) ListNews(ctx context.Context, q NewsQuery, fields []string, withTotal bool) ([]interface{}, ListMeta, error) {
+	var meta ListMeta
+	var w where
+	if q.Topic != "" {
+		w.add("n.topic_id = (SELECT id FROM topics WHERE "+idColumn(q.Topic)+" = ?)", q.Topic)
+	}
+	q.filter(&w)
+
+	// The total ignores the cursor and offset, only the filters apply
+	if withTotal {
+		var total int64
+		err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+q.source()+" "+w.String(), w.args...).Scan(&total)
+		if err != nil {
+			return nil, meta, storeError(err)
+		}
+		meta.Total = &total
+	}
+
+	page := q.Page
+	if page.After != nil {
+		w.add("(n.created_at, n.id) < (?, ?)", page.After.CreatedAt, page.After.ID)
+	}
+
+	sel := newsSelect{fields: fields, includeTopic: q.IncludeTopic, cursor: q.keyset()}
+
+	// One extra row tells us whether there is a next page
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+sel.columns()+`
+		FROM `+q.source()+sel.join()+`
+		`+w.String()+`
+		ORDER BY `+q.Sort.orderBy("n.id")+`
+		LIMIT `+w.arg(page.Limit+1)+` OFFSET `+w.arg(page.Offset), w.args...)
+	if err != nil {
+		return nil, meta, storeError(err)
+	}
+	defer rows.Close()
+
+	var items []interface{}
+	var positions []newsCursor
+	for rows.Next() {
+		item, pos, err := sel.scan(rows)
+		if err != nil {
+			return nil, meta, storeError(err)
+		}
+		items = append(items, item)
+		positions = append(positions, pos)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, meta, storeError(err)
+	}
+
+	if len(items) > page.Limit {
+		items = items[:page.Limit]
+		if q.keyset() {
+			meta.NextCursor = positions[page.Limit-1].encode()
+		}
+	}
+	return items, meta, nil
+}
+
+// CreateNews inserts the topic if needed, the article and any moderation
+// flag in one transaction, so a failure leaves no partial rows behind
+func (s *pgStore) CreateNews(ctx context.Context, req CreateNewsRequest, verdict ModerationResult) (News, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return News{}, storeError(err)
+	}
+	defer tx.Rollback()
+
+	topic, err := resolveTopic(ctx, tx, &req)
+	if err == sql.ErrNoRows {
+		return News{}, &ForeignKeyError{Ref: "topic"}
+	} else if err != nil {
+		return News{}, storeError(err)
+	}
+
+	created := News{Title: req.Title, Content: req.Content, TopicID: topic.ID, CreatedBy: req.CreatedBy, Topic: topic.summary()}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO news (title, content, topic_id, created_at, updated_at, created_by)
+		VALUES ($1, $2, $3, $4, $4, $5)
+		RETURNING id, uuid, created_at, updated_at
+	`, req.Title, req.Content, topic.ID, timestamp(), req.CreatedBy).Scan(&created.ID, &created.UUID, &created.CreatedAt, &created.UpdatedAt)
+	if err != nil {
+		return News{}, storeError(err)
+	}
+	if err := recordModerationFlag(ctx, tx, created.ID, verdict); err != nil {
+		return News{}, storeError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return News{}, storeError(err)
+	}
+	return created, nil
+}
+
+// UpdateNews writes the change and any moderation flag together,
+// returning the written row so no follow-up read is needed
+func (s *pgStore) UpdateNews(ctx context.Context, id string, change NewsChange, verdict ModerationResult) (News, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return News{}, storeError(err)
+	}
+	defer tx.Rollback()
+
+	if change.TopicID != nil {
+		var topicExists bool
+		err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM topics WHERE id = $1)", *change.TopicID).Scan(&topicExists)
+		if err != nil {
+			return News{}, storeError(err)
+		}
+		if !topicExists {
+			return News{}, &ForeignKeyError{Ref: "topic"}
+		}
+	}
+
+	p := patchSet{touch: change.Replace}
+	if change.Title != nil {
+		p.add("title", *change.Title)
+	}
+	if change.Content != nil {
+		p.add("content", *change.Content)
+	}
+	if change.TopicID != nil {
+		p.add("topic_id", *change.TopicID)
+	}
+	set, where := p.finish(id)
+
+	var news News
+	err = tx.QueryRowContext(ctx, `
+		UPDATE news
+		SET `+set+`
+		WHERE `+where+`
+		RETURNING id, uuid, title, content, topic_id, created_at, updated_at, created_by
+	`, p.args...).Scan(&news.ID, &news.UUID, &news.Title, &news.Content, &news.TopicID, &news.CreatedAt, &news.UpdatedAt, &news.CreatedBy)
+
+	if err == sql.ErrNoRows {
+		var archived bool
+		err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM news_archive WHERE "+idColumn(id)+" = $1)", id).Scan(&archived)
+		if err == nil && archived {
+			return News{}, ErrArchived
+		}
+		return News{}, ErrNotFound
+	} else if err != nil {
+		return News{}, storeError(err)
+	}
+	if err := recordModerationFlag(ctx, tx, news.ID, verdict); err != nil {
+		return News{}, storeError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return News{}, storeError(err)
+	}
+	return news, nil
+}
+
+func (s *pgStore) DeleteNews(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storeError(err)
+	}
+	defer tx.Rollback()
+
+	var newsID int
+	err = tx.QueryRowContext(ctx, "DELETE FROM news WHERE "+idColumn(id)+" = $1 RETURNING id", id).Scan(&newsID)
+
+	// The article may have been archived
+	if err == sql.ErrNoRows {
+		err = tx.QueryRowContext(ctx, "DELETE FROM news_archive WHERE "+idColumn(id)+" = $1 RETURNING id", id).Scan(&newsID)
+	}
+	if err != nil {
+		return storeError(err)
+	}
+
+	// Remove it from any collections, closing the gaps it leaves
+	if err := removeFromCollections(ctx, tx, newsID); err != nil {
+		return storeError(err)
+	}
+	return storeError(tx.Commit())
+}
+
+func (s *pgStore) ListTopics(ctx context.Context, order Sort) ([]Topic, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+topicSelectColumns+`
+		FROM topics
+		ORDER BY `+order.orderBy("id"))
+	if err != nil {
+		return nil, storeError(err)
+	}
+	defer rows.Close()
+
+	var topics []Topic
+	for rows.Next() {
+		topic, err := scanTopic(rows)
+		if err != nil {
+			return nil, storeError(err)
+		}
+		topics = append(topics, topic)
+	}
+	return topics, storeError(rows.Err())
+}
+
+func (s *pgStore) GetTopic(ctx context.Context, id string) (Topic, error) {
+	topic, err := scanTopic(s.db.QueryRowContext(ctx, `
+		SELECT `+topicSelectColumns+`
+		FROM topics
+		WHERE `+idColumn(id)+` = $1
+	`, id))
+	return topic, storeError(err)
+}
+
+func (s *pgStore) CreateTopic(ctx context.Context, topic Topic) (Topic, error) {
+	created, err := scanTopic(s.db.QueryRowContext(ctx, `
+		INSERT INTO topics (name, description, long_description, meta_title, meta_description, header_image_url, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING `+topicSelectColumns,
+		topic.Name, topic.Tagline, topic.LongDescription, topic.MetaTitle, topic.MetaDescription, topic.HeaderImageURL, timestamp()))
+	return created, storeError(err)
+}
+
+func (s *pgStore) UpdateTopic(ctx context.Context, id string, change TopicChange) (Topic, error) {
+	p := patchSet{touch: change.Replace}
+	for _, f := range []struct {
+		column string
+		value  *string
+	}{
+		{"name", change.Name},
+		{"description", change.Tagline},
+		{"long_description", change.LongDescription},
+		{"meta_title", change.MetaTitle},
+		{"meta_description", change.MetaDescription},
+		{"header_image_url", change.HeaderImageURL},
+	} {
+		if f.value != nil {
+			p.add(f.column, *f.value)
+		}
+	}
+	set, where := p.finish(id)
+
+	topic, err := scanTopic(s.db.QueryRowContext(ctx, `
+		UPDATE topics
+		SET `+set+`
+		WHERE `+where+`
+		RETURNING `+topicSelectColumns,
+		p.args...))
+	return topic, storeError(err)
+}
+
+func (s *pgStore) DeleteTopic(ctx context.Context, id string) error {
+	// Check if there are news articles with this topic first, archived ones included
+	var count int
+	err := s.db.QueryRowContext(ctx, `
+		WITH t AS (SELECT id FROM topics WHERE `+idColumn(id)+` = $1)
+		SELECT (SELECT COUNT(*) FROM news WHERE topic_id IN (SELECT id FROM t))
+			+ (SELECT COUNT(*) FROM news_archive WHERE topic_id IN (SELECT id FROM t))
+	`, id).Scan(&count)
+	if err != nil {
+		return storeError(err)
+	}
+	if count > 0 {
+		return ErrInUse
+	}
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM topics WHERE "+idColumn(id)+" = $1", id)
+	if err != nil {
+		return storeError(err)
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return storeError(err)
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// patchSet builds the SET list of an update. Each assignment is paired
+// with a change test; SET expressions see the old row, so updated_at only
+// moves when some value actually differs, or always when touch is set.
+type patchSet struct {
+	sets, changed []string
+	args          []interface{}
+	touch         bool
+}
+
+func (p *patchSet) add(column string, value interface{}) {
+	p.args = append(p.args, value)
+	p.sets = append(p.sets, fmt.Sprintf("%s = $%d", column, len(p.args)))
+	p.changed = append(p.changed, fmt.Sprintf("%s IS DISTINCT FROM $%d", column, len(p.args)))
+}
+
+// finish adds the updated_at assignment and returns the SET list and the
+// WHERE condition matching id
+func (p *patchSet) finish(id string) (set, where string) {
+	p.args = append(p.args, timestamp(), id)
+	if p.touch {
+		p.sets = append(p.sets, fmt.Sprintf("updated_at = $%d", len(p.args)-1))
+	} else {
+		p.sets = append(p.sets, fmt.Sprintf("updated_at = CASE WHEN %s THEN $%d ELSE updated_at END", strings.Join(p.changed, " OR "), len(p.args)-1))
+	}
+	return strings.Join(p.sets, ", "), fmt.Sprintf("%s = $%d", idColumn(id), len(p.args))
+}
@@ -0,0 +1,112 @@
+// pool.go
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Connection pool defaults. They keep a single replica well inside
+// Postgres's default max_connections of 100 and recycle connections often
+// enough to follow failovers and pgbouncer restarts.
+const (
+	defaultMaxOpenConns    = 25
+	defaultMaxIdleConns    = 10
+	defaultConnMaxLifetime = 30 * time.Minute
+	defaultConnMaxIdleTime = 5 * time.Minute
+)
+
+// poolSettings is the connection pool configuration applied in initDB.
+// A zero lifetime or idle time means connections are never closed for age.
+type poolSettings struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// loadPoolSettings reads DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS,
+// DB_CONN_MAX_LIFETIME and DB_CONN_MAX_IDLE_TIME through getenv
+func loadPoolSettings(getenv func(string) string) (poolSettings, error) {
+	s := poolSettings{
+		MaxOpenConns:    defaultMaxOpenConns,
+		MaxIdleConns:    defaultMaxIdleConns,
+		ConnMaxLifetime: defaultConnMaxLifetime,
+		ConnMaxIdleTime: defaultConnMaxIdleTime,
+	}
+	if v := getenv("DB_MAX_OPEN_CONNS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return s, fmt.Errorf("Invalid DB_MAX_OPEN_CONNS %q: must be a positive integer", v)
+		}
+		s.MaxOpenConns = n
+	}
+	if s.MaxIdleConns > s.MaxOpenConns {
+		s.MaxIdleConns = s.MaxOpenConns
+	}
+	if v := getenv("DB_MAX_IDLE_CONNS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > s.MaxOpenConns {
+			return s, fmt.Errorf("Invalid DB_MAX_IDLE_CONNS %q: must be an integer from 0 to DB_MAX_OPEN_CONNS (%d)", v, s.MaxOpenConns)
+		}
+		s.MaxIdleConns = n
+	}
+	for _, d := range []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"DB_CONN_MAX_LIFETIME", &s.ConnMaxLifetime},
+		{"DB_CONN_MAX_IDLE_TIME", &s.ConnMaxIdleTime},
+	} {
+		if v := getenv(d.name); v != "" {
+			parsed, err := time.ParseDuration(v)
+			if err != nil || parsed < 0 {
+				return s, fmt.Errorf("Invalid %s %q: must be a non-negative duration", d.name, v)
+			}
+			*d.dst = parsed
+		}
+	}
+	return s, nil
+}
+
+func (s poolSettings) apply(db *sql.DB) {
+	db.SetMaxOpenConns(s.MaxOpenConns)
+	db.SetMaxIdleConns(s.MaxIdleConns)
+	db.SetConnMaxLifetime(s.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(s.ConnMaxIdleTime)
+}
+
+func (s poolSettings) String() string {
+	return fmt.Sprintf("max open %d, max idle %d, max lifetime %s, max idle time %s",
+		s.MaxOpenConns, s.MaxIdleConns, s.ConnMaxLifetime, s.ConnMaxIdleTime)
+}
+
+// PoolStats is the health endpoint's view of sql.DBStats
+type PoolStats struct {
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+	MaxIdleClosed      int64  `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64  `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`
+}
+
+func poolStats(db *sql.DB) PoolStats {
+	s := db.Stats()
+	return PoolStats{
+		MaxOpenConnections: s.MaxOpenConnections,
+		OpenConnections:    s.OpenConnections,
+		InUse:              s.InUse,
+		Idle:               s.Idle,
+		WaitCount:          s.WaitCount,
+		WaitDuration:       s.WaitDuration.String(),
+		MaxIdleClosed:      s.MaxIdleClosed,
+		MaxIdleTimeClosed:  s.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  s.MaxLifetimeClosed,
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPoolSettings(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+
+	s, err := loadPoolSettings(env(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, poolSettings{defaultMaxOpenConns, defaultMaxIdleConns, defaultConnMaxLifetime, defaultConnMaxIdleTime}, s)
+
+	s, err = loadPoolSettings(env(map[string]string{
+		"DB_MAX_OPEN_CONNS":     "50",
+		"DB_MAX_IDLE_CONNS":     "0",
+		"DB_CONN_MAX_LIFETIME":  "1h",
+		"DB_CONN_MAX_IDLE_TIME": "0",
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, poolSettings{50, 0, time.Hour, 0}, s)
+
+	// A small pool caps the default idle count instead of failing
+	s, err = loadPoolSettings(env(map[string]string{"DB_MAX_OPEN_CONNS": "4"}))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, s.MaxIdleConns)
+
+	for _, bad := range []map[string]string{
+		{"DB_MAX_OPEN_CONNS": "0"},
+		{"DB_MAX_OPEN_CONNS": "-5"},
+		{"DB_MAX_OPEN_CONNS": "many"},
+		{"DB_MAX_IDLE_CONNS": "-1"},
+		{"DB_MAX_OPEN_CONNS": "5", "DB_MAX_IDLE_CONNS": "6"},
+		{"DB_CONN_MAX_LIFETIME": "-1m"},
+		{"DB_CONN_MAX_IDLE_TIME": "soon"},
+	} {
+		_, err := loadPoolSettings(env(bad))
+		assert.Error(t, err, "%v", bad)
+	}
+}
+
+func TestHealthCheckReportsPoolStats(t *testing.T) {
+	pool, err := sql.Open("postgres", "postgres://localhost/unused")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer pool.Close()
+	poolSettings{MaxOpenConns: 7}.apply(pool)
+
+	saved := db
+	db = pool
+	defer func() { db = saved }()
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	rec := httptest.NewRecorder()
+	assert.NoError(t, healthCheck(setupEcho().NewContext(req, rec)))
+
+	var response HealthResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	// Stats are reported whether or not the ping succeeds
+	if assert.NotNil(t, response.DB) {
+		assert.Equal(t, 7, response.DB.MaxOpenConnections)
+		assert.Equal(t, 0, response.DB.InUse)
+	}
+}
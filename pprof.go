@@ -0,0 +1,72 @@
+// pprof.go
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// headerDebugSecret carries DEBUG_PPROF_SECRET on profiling requests
+const headerDebugSecret = "X-Debug-Secret"
+
+// minDebugSecretChars keeps the secret out of guessing range
+const minDebugSecretChars = 16
+
+// pprofSecret guards /debug/pprof. The secret is empty and the routes are
+// unregistered unless DEBUG_PPROF=true.
+var pprofSecret string
+
+func loadPprofSettings() {
+	v := os.Getenv("DEBUG_PPROF")
+	if v == "" {
+		return
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		fatalf("Invalid DEBUG_PPROF %q: must be a boolean", v)
+	}
+	if !enabled {
+		return
+	}
+	secret := os.Getenv("DEBUG_PPROF_SECRET")
+	if len(secret) < minDebugSecretChars {
+		fatalf("DEBUG_PPROF=true needs DEBUG_PPROF_SECRET of at least %d characters", minDebugSecretChars)
+	}
+	pprofSecret = secret
+}
+
+// registerPprof mounts net/http/pprof under /debug/pprof when enabled.
+// Every request must carry the secret in X-Debug-Secret, e.g.
+//
+//	curl -H "X-Debug-Secret: $DEBUG_PPROF_SECRET" -o heap.pprof http://host/debug/pprof/heap
+//	go tool pprof heap.pprof
+func registerPprof(e *echo.Echo) {
+	if pprofSecret == "" {
+		return
+	}
+	g := e.Group("/debug/pprof", requireDebugSecret)
+	g.GET("", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
+	g.GET("/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+	g.GET("/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)))
+	g.GET("/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	g.POST("/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	g.GET("/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)))
+	// Named profiles: heap, goroutine, allocs, block, mutex, threadcreate
+	g.GET("/*", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
+}
+
+// requireDebugSecret answers 401 unless the request carries the secret
+func requireDebugSecret(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		given := c.Request().Header.Get(headerDebugSecret)
+		if subtle.ConstantTimeCompare([]byte(given), []byte(pprofSecret)) != 1 {
+			return c.JSON(http.StatusUnauthorized, ErrorResponse{Code: codeUnauthorized, Message: "Missing or invalid " + headerDebugSecret + " header"})
+		}
+		return next(c)
+	}
+}
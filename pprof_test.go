@@ -0,0 +1,56 @@
+// pprof_test.go
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func usePprofSecret(t *testing.T, secret string) {
+	saved := pprofSecret
+	pprofSecret = secret
+	t.Cleanup(func() { pprofSecret = saved })
+}
+
+func pprofRequest(e http.Handler, path, secret string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if secret != "" {
+		req.Header.Set(headerDebugSecret, secret)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPprofDisabled(t *testing.T) {
+	usePprofSecret(t, "")
+	e := newRouter()
+	for _, path := range []string{"/debug/pprof", "/debug/pprof/heap", "/debug/pprof/cmdline"} {
+		assert.Equal(t, http.StatusNotFound, pprofRequest(e, path, "anything").Code, path)
+	}
+}
+
+func TestPprofRequiresSecret(t *testing.T) {
+	const secret = "0123456789abcdef-test"
+	usePprofSecret(t, secret)
+	e := newRouter()
+
+	for _, path := range []string{"/debug/pprof", "/debug/pprof/heap", "/debug/pprof/cmdline"} {
+		rec := pprofRequest(e, path, "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, path)
+		assert.Contains(t, rec.Body.String(), codeUnauthorized)
+		assert.Equal(t, http.StatusUnauthorized, pprofRequest(e, path, "wrong").Code, path)
+	}
+
+	rec := pprofRequest(e, "/debug/pprof", secret)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "goroutine")
+	rec = pprofRequest(e, "/debug/pprof/heap?debug=1", secret)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "heap profile")
+	assert.Equal(t, http.StatusOK, pprofRequest(e, "/debug/pprof/cmdline", secret).Code)
+	assert.Equal(t, http.StatusNotFound, pprofRequest(e, "/debug/pprof/nonexistent", secret).Code)
+}
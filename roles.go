@@ -0,0 +1,128 @@
+// roles.go
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Role is what a user may do. Each role includes the ones before it:
+// readers only read, editors also write news and collections, and admins
+// also manage topics, delete anything and use /api/admin.
+type Role string
+
+const (
+	roleReader Role = "reader"
+	roleEditor Role = "editor"
+	roleAdmin  Role = "admin"
+)
+
+var roleRanks = map[Role]int{roleReader: 1, roleEditor: 2, roleAdmin: 3}
+
+// validRole reports whether r is one of the roles above
+func validRole(r Role) bool {
+	return roleRanks[r] > 0
+}
+
+// includes reports whether r may do what required may
+func (r Role) includes(required Role) bool {
+	return roleRanks[r] >= roleRanks[required]
+}
+
+// routeRoles is the role each write needs, keyed by method and route
+// path. Writes not listed need admin, so a new route is closed until it
+// is added here. Reads need no token, except under /api/admin where every
+// method needs admin.
+var routeRoles = map[string]Role{
+	"POST /api/news":                   roleEditor,
+	"POST /api/news/publish":           roleEditor,
+	"PUT /api/news/:id":                roleEditor,
+	"PATCH /api/news/:id":              roleEditor,
+	"POST /api/collections":            roleEditor,
+	"PUT /api/collections/:slug":       roleEditor,
+	"POST /api/collections/:slug/news": roleEditor,
+	"PUT /api/collections/:slug/news":  roleEditor,
+}
+
+// requiredRole is the role a request needs, "" when it needs none
+func requiredRole(method, path string) Role {
+	if !needsToken(method, path) {
+		return ""
+	}
+	if role, ok := routeRoles[method+" "+path]; ok {
+		return role
+	}
+	return roleAdmin
+}
+
+// authorize answers 403 when the token's role does not cover the route.
+// It runs after authenticate, which has already turned away requests
+// without a valid token.
+func authorize(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		if jwtSecret == nil || !needsToken(req.Method, req.URL.Path) {
+			return next(c)
+		}
+		required := requiredRole(req.Method, c.Path())
+		if p, ok := currentPrincipal(req.Context()); !ok || !p.Role.includes(required) {
+			return c.JSON(http.StatusForbidden, ErrorResponse{Code: codeForbidden, Message: "This requires the " + string(required) + " role"})
+		}
+		return next(c)
+	}
+}
+
+type roleRequest struct {
+	Role Role `json:"role"`
+}
+
+// updateUserRole sets a user's role. It applies from their next login,
+// since tokens already issued carry the old one.
+func updateUserRole(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidParameter, Message: "Invalid user ID"})
+	}
+	body := new(roleRequest)
+	if err := c.Bind(body); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidPayload, Message: "Invalid request payload"})
+	}
+	if !validRole(body.Role) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    codeValidationFailed,
+			Message: "Role must be reader, editor or admin",
+			Details: map[string]string{"role": "must be reader, editor or admin"},
+		})
+	}
+
+	var user User
+	err = db.QueryRowContext(ctx, `
+		UPDATE users SET role = $1 WHERE id = $2
+		RETURNING id, email, role, created_at
+	`, body.Role, id).Scan(&user.ID, &user.Email, &user.Role, &user.CreatedAt)
+	if err != nil {
+		return respondError(c, err, "User", "Failed to update user role")
+	}
+	return c.JSON(http.StatusOK, user)
+}
+
+// grantAdmin makes the user with email an admin, for -grant-admin. It is
+// how the first admin is created.
+func grantAdmin(ctx context.Context, email string) error {
+	normalized, _ := normalizeEmail(email)
+	res, err := db.ExecContext(ctx, "UPDATE users SET role = $1 WHERE email = $2", roleAdmin, normalized)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// roles_test.go
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Every entry in routeRoles must name a registered route, or a typo would
+// silently leave that route admin-only
+func TestRouteRolesMatchRoutes(t *testing.T) {
+	registered := map[string]bool{}
+	for _, r := range newRouter().Routes() {
+		registered[r.Method+" "+r.Path] = true
+	}
+	for route := range routeRoles {
+		assert.True(t, registered[route], route)
+	}
+
+	assert.Equal(t, Role(""), requiredRole(http.MethodGet, "/api/news"))
+	assert.Equal(t, Role(""), requiredRole(http.MethodPost, "/api/auth/login"))
+	assert.Equal(t, roleEditor, requiredRole(http.MethodPatch, "/api/news/:id"))
+	assert.Equal(t, roleAdmin, requiredRole(http.MethodDelete, "/api/news/:id"))
+	assert.Equal(t, roleAdmin, requiredRole(http.MethodPost, "/api/not-yet-listed"))
+	assert.Equal(t, roleAdmin, requiredRole(http.MethodGet, "/api/admin/config"))
+	assert.Equal(t, roleAdmin, requiredRole(http.MethodHead, "/api/admin/integrity"))
+}
+
+// Admin reads expose configuration and internals, so they need a token
+// like writes do
+func TestAdminReadsNeedAdmin(t *testing.T) {
+	useStore(t, newMemStore())
+	useJWTSecret(t, testJWTSecret)
+	s := newTestServer(t)
+
+	var body ErrorResponse
+	if s.expect(s.do(http.MethodGet, "/api/admin/config", nil), http.StatusUnauthorized, &body) {
+		assert.Equal(t, codeUnauthorized, body.Code)
+	}
+	s.token, _ = signToken(principal{UserID: 1, Role: roleReader}, clock.Now())
+	if s.expect(s.do(http.MethodGet, "/api/admin/config", nil), http.StatusForbidden, &body) {
+		assert.Equal(t, codeForbidden, body.Code)
+	}
+	s.token, _ = signToken(principal{UserID: 1, Role: roleAdmin}, clock.Now())
+	s.expect(s.do(http.MethodGet, "/api/admin/config", nil), http.StatusOK, nil)
+}
+
+func TestRolesByRouteClass(t *testing.T) {
+	useStore(t, newMemStore())
+	useJWTSecret(t, testJWTSecret)
+
+	type request struct{ method, path string }
+	classes := []struct {
+		name     string
+		requests []request
+		allowed  map[Role]bool
+	}{
+		{"read", []request{
+			{http.MethodGet, "/api/news"},
+			{http.MethodGet, "/api/topics"},
+		}, map[Role]bool{roleReader: true, roleEditor: true, roleAdmin: true}},
+		{"write news", []request{
+			{http.MethodPost, "/api/news"},
+			{http.MethodPut, "/api/news/1"},
+			{http.MethodPatch, "/api/news/1"},
+			{http.MethodPost, "/api/collections"},
+			{http.MethodPut, "/api/collections/series/news"},
+		}, map[Role]bool{roleEditor: true, roleAdmin: true}},
+		{"manage topics", []request{
+			{http.MethodPost, "/api/topics"},
+			{http.MethodPut, "/api/topics/1"},
+			{http.MethodPatch, "/api/topics/1"},
+		}, map[Role]bool{roleAdmin: true}},
+		{"delete", []request{
+			{http.MethodDelete, "/api/news/1"},
+			{http.MethodDelete, "/api/topics/1"},
+			{http.MethodDelete, "/api/collections/series"},
+			{http.MethodDelete, "/api/collections/series/news/1"},
+		}, map[Role]bool{roleAdmin: true}},
+		{"admin", []request{
+			{http.MethodGet, "/api/admin/archiver"},
+			{http.MethodGet, "/api/admin/config"},
+			{http.MethodPut, "/api/admin/archiver"},
+			{http.MethodPost, "/api/admin/sequences/repair"},
+			{http.MethodPut, "/api/admin/users/1/role"},
+		}, map[Role]bool{roleAdmin: true}},
+	}
+
+	for _, class := range classes {
+		for _, role := range []Role{roleReader, roleEditor, roleAdmin} {
+			t.Run(class.name+"/"+string(role), func(t *testing.T) {
+				s := newTestServer(t)
+				s.token, _ = signToken(principal{UserID: 1, Role: role}, clock.Now())
+				for _, r := range class.requests {
+					rec := s.do(r.method, r.path, "{}")
+					if class.allowed[role] {
+						assert.NotContains(t, []int{http.StatusUnauthorized, http.StatusForbidden}, rec.Code, r.method+" "+r.path)
+						continue
+					}
+					var body ErrorResponse
+					if s.expect(rec, http.StatusForbidden, &body) {
+						assert.Equal(t, codeForbidden, body.Code)
+						assert.True(t, strings.HasPrefix(body.Message, "This requires the "), body.Message)
+					}
+				}
+			})
+		}
+	}
+}
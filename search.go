@@ -0,0 +1,90 @@
+// search.go
+package main
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// SearchResult is an article with its relevance to the query
+type SearchResult struct {
+	News
+	Rank float64 `json:"rank"`
+}
+
+// searchNews runs a full-text query over news, best matches first. It
+// takes the listing filters except sort and cursor, since results are
+// ordered by rank.
+func searchNews(c echo.Context) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	q, err := parseNewsQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidParameter, Message: err.Error()})
+	}
+	if q.Page.After != nil || c.QueryParam("sort") != "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidParameter, Message: "Search results are ordered by rank and paginated by offset"})
+	}
+	text := q.Search
+	if text == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidParameter, Message: "Search query is required"})
+	}
+	q.Search = ""
+
+	// Queries made only of stop words or punctuation parse to nothing
+	var nodes int
+	if err := db.QueryRowContext(ctx, "SELECT numnode(plainto_tsquery('english', $1))", text).Scan(&nodes); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to search news"})
+	}
+	if nodes == 0 {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidParameter, Message: "Search query has no searchable terms"})
+	}
+
+	var w where
+	query := ", plainto_tsquery('english', " + w.arg(text) + ") query"
+	w.add("n.search_vector @@ query")
+	q.filter(&w)
+
+	var meta ListMeta
+	var total int64
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+q.source()+query+" "+w.String(), w.args...).Scan(&total)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to search news"})
+	}
+	meta.Total = &total
+
+	columns, join := "", ""
+	if q.IncludeTopic {
+		columns, join = topicColumns, topicJoin
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT n.id, n.uuid, n.title, n.content, n.topic_id, n.created_at, n.updated_at, n.archived,
+			ts_rank(n.search_vector, query) AS rank`+columns+`
+		FROM `+q.source()+join+query+`
+		`+w.String()+`
+		ORDER BY rank DESC, n.id DESC
+		LIMIT `+w.arg(q.Page.Limit)+` OFFSET `+w.arg(q.Page.Offset), w.args...)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to search news"})
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		var topic topicScan
+		dest := []interface{}{&r.ID, &r.UUID, &r.Title, &r.Content, &r.TopicID, &r.CreatedAt, &r.UpdatedAt, &r.Archived, &r.Rank}
+		if q.IncludeTopic {
+			dest = append(dest, topic.dest()...)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Error scanning news row"})
+		}
+		r.Topic = topic.topic()
+		results = append(results, r)
+	}
+
+	return respondListMeta(c, results, meta)
+}
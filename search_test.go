@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchNews(t *testing.T) {
+	requirePostgres(t)
+	e := setupEcho()
+
+	var topicID int
+	assert.NoError(t, db.QueryRow("INSERT INTO topics (name) VALUES ('Full Text') RETURNING id").Scan(&topicID))
+	defer db.Exec("DELETE FROM topics WHERE id = $1", topicID)
+
+	for _, a := range [][2]string{
+		{"Central bank raises rates", "The bank cited inflation."},
+		{"Weekend weather", "Sunny with a chance of inflation jokes and more inflation talk."},
+		{"Inflation hits record high", "Prices rose across the board."},
+		{"Gardening tips", "Nothing to do with money."},
+		// Longer than a tsvector can hold; the capped column must still accept it
+		{"Huge transcript", strings.Repeat("inflation pressure wording ", 80000)},
+	} {
+		_, err := db.Exec(`
+			INSERT INTO news (title, content, topic_id, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $4)
+		`, a[0], a[1], topicID, timestamp())
+		assert.NoError(t, err, a[0])
+	}
+	defer db.Exec("DELETE FROM news WHERE topic_id = $1", topicID)
+
+	search := func(query string) (*httptest.ResponseRecorder, []SearchResult) {
+		req := httptest.NewRequest(http.MethodGet, "/api/news/search?"+query, nil)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, searchNews(e.NewContext(req, rec)))
+
+		var results []SearchResult
+		if rec.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+		}
+		return rec, results
+	}
+
+	rec, results := search("q=inflation")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "4", rec.Header().Get("X-Total-Count"))
+	if assert.Len(t, results, 4) {
+		// A title match outranks content matches
+		assert.Equal(t, "Inflation hits record high", results[0].Title)
+		for i := 1; i < len(results); i++ {
+			assert.GreaterOrEqual(t, results[i-1].Rank, results[i].Rank)
+		}
+		assert.Greater(t, results[0].Rank, 0.0)
+	}
+
+	rec, results = search("q=inflation&limit=1&offset=1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, results, 1)
+
+	for _, q := range []string{"q=", "q=" + url.QueryEscape("the and of"), "q=" + url.QueryEscape("?!"), "q=inflation&sort=title"} {
+		rec, _ = search(q)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, q)
+	}
+}
@@ -0,0 +1,141 @@
+// seed.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// seedTopics are the topics -seed creates, each with seedArticlesPerTopic
+// articles
+var seedTopics = []struct {
+	name, tagline string
+}{
+	{"Politics", "Elections, policy and government"},
+	{"Technology", "Software, hardware and the companies behind them"},
+	{"Science", "Research, space and the natural world"},
+	{"Business", "Markets, trade and the economy"},
+	{"Sports", "Results, transfers and tournaments"},
+	{"Health", "Medicine, public health and wellbeing"},
+}
+
+const seedArticlesPerTopic = 6
+
+// seedSpacing separates consecutive seeded articles, spreading them over
+// roughly nine days back from the time of seeding
+const seedSpacing = 6*time.Hour + 17*time.Minute
+
+// seedRequested reports whether the -seed flag or SEED asks for
+// development data
+func seedRequested(flag bool) bool {
+	v := os.Getenv("SEED")
+	if flag || v == "" {
+		return flag
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		fatalf("Invalid SEED %q: must be a boolean", v)
+	}
+	return enabled
+}
+
+// seedClock hands out the created_at of each seeded article
+type seedClock struct {
+	now time.Time
+}
+
+func (c *seedClock) Now() time.Time { return c.now }
+
+// seedData fills s with the development topics and articles, skipping
+// topics whose name exists and articles whose title already exists in
+// their topic, so running it again adds nothing. It swaps the global
+// clock to date the articles and must run before the server or archiver
+// start. It returns how many topics and articles it created.
+func seedData(ctx context.Context, s Store) (topics, articles int, err error) {
+	sc := &seedClock{now: timestamp()}
+	prev := clock
+	clock = sc
+	defer func() { clock = prev }()
+
+	existing, err := s.ListTopics(ctx, Sort{Column: "name"})
+	if err != nil {
+		return 0, 0, err
+	}
+	byName := map[string]Topic{}
+	for _, t := range existing {
+		byName[t.Name] = t
+	}
+
+	base := sc.now
+	for i, st := range seedTopics {
+		topic, ok := byName[st.name]
+		if !ok {
+			sc.now = base.Add(-time.Duration(len(seedTopics)*seedArticlesPerTopic) * seedSpacing)
+			if topic, err = s.CreateTopic(ctx, Topic{Name: st.name, Tagline: st.tagline}); err != nil {
+				return topics, articles, fmt.Errorf("topic %s: %w", st.name, err)
+			}
+			topics++
+		}
+
+		titles, err := seedTitles(ctx, s, topic.ID)
+		if err != nil {
+			return topics, articles, fmt.Errorf("topic %s: %w", st.name, err)
+		}
+		for n := 1; n <= seedArticlesPerTopic; n++ {
+			title := fmt.Sprintf("%s update #%d", st.name, n)
+			if titles[title] {
+				continue
+			}
+			// Interleave topics so every page of the feed mixes them
+			sc.now = base.Add(-time.Duration((n-1)*len(seedTopics)+i) * seedSpacing)
+			req := CreateNewsRequest{
+				Title:   title,
+				Content: fmt.Sprintf("Sample %s article number %d, created by the development seed.", st.name, n),
+				TopicID: topic.ID,
+			}
+			if _, err := s.CreateNews(ctx, req, ModerationResult{Verdict: verdictAllow}); err != nil {
+				return topics, articles, fmt.Errorf("article %q: %w", title, err)
+			}
+			articles++
+		}
+	}
+	return topics, articles, nil
+}
+
+// seedTitles returns the titles of every article in the topic, archived
+// ones included
+func seedTitles(ctx context.Context, s Store, topicID int) (map[string]bool, error) {
+	titles := map[string]bool{}
+	q := NewsQuery{
+		Page:            Page{Limit: 100},
+		Sort:            defaultNewsSort,
+		Topic:           strconv.Itoa(topicID),
+		IncludeArchived: true,
+	}
+	for {
+		items, _, err := s.ListNews(ctx, q, nil, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			titles[item.(News).Title] = true
+		}
+		if len(items) < q.Page.Limit {
+			return titles, nil
+		}
+		q.Page.Offset += q.Page.Limit
+	}
+}
+
+// runSeed seeds the active store at startup, for -seed and SEED=true
+func runSeed() {
+	topics, articles, err := seedData(context.Background(), store)
+	if err != nil {
+		fatal("Seeding failed", "error", err)
+	}
+	slog.Info("Seeded development data", "topics", topics, "articles", articles)
+}
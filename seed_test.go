@@ -0,0 +1,65 @@
+// seed_test.go
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func seedCounts(t *testing.T) (topics int, articles int64) {
+	ctx := context.Background()
+	all, err := store.ListTopics(ctx, Sort{Column: "name"})
+	assert.NoError(t, err)
+	q := NewsQuery{Page: Page{Limit: 1}, Sort: defaultNewsSort, IncludeArchived: true}
+	_, meta, err := store.ListNews(ctx, q, nil, true)
+	assert.NoError(t, err)
+	return len(all), *meta.Total
+}
+
+// Seeding twice leaves the same rows as seeding once
+func TestSeedIsIdempotent(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s *testServer) {
+		ctx := context.Background()
+		topicsBefore, articlesBefore := seedCounts(t)
+
+		topics, articles, err := seedData(ctx, store)
+		if !assert.NoError(t, err) {
+			return
+		}
+		topicsAfter, articlesAfter := seedCounts(t)
+		assert.Equal(t, topicsBefore+topics, topicsAfter)
+		assert.Equal(t, articlesBefore+int64(articles), articlesAfter)
+
+		topics, articles, err = seedData(ctx, store)
+		assert.NoError(t, err)
+		assert.Zero(t, topics)
+		assert.Zero(t, articles)
+		topicsAgain, articlesAgain := seedCounts(t)
+		assert.Equal(t, topicsAfter, topicsAgain)
+		assert.Equal(t, articlesAfter, articlesAgain)
+	})
+}
+
+func TestSeedEmptyStore(t *testing.T) {
+	useStore(t, newMemStore())
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	useFakeClock(t, now)
+
+	topics, articles, err := seedData(context.Background(), store)
+	assert.NoError(t, err)
+	assert.Equal(t, len(seedTopics), topics)
+	assert.Equal(t, len(seedTopics)*seedArticlesPerTopic, articles)
+	assert.Equal(t, now, clock.Now(), "clock restored")
+
+	// Articles are spread out, newest at the time of seeding
+	q := NewsQuery{Page: Page{Limit: 100}, Sort: defaultNewsSort}
+	items, _, err := store.ListNews(context.Background(), q, nil, false)
+	assert.NoError(t, err)
+	if assert.Len(t, items, articles) {
+		assert.Equal(t, now, items[0].(News).CreatedAt)
+		assert.Equal(t, now.Add(-time.Duration(articles-1)*seedSpacing), items[articles-1].(News).CreatedAt)
+	}
+}
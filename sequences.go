@@ -0,0 +1,209 @@
+// sequences.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// serialSequence is a table whose ids come from its SERIAL sequence.
+// Tables lists every table holding ids the sequence hands out: articles
+// keep their news id when archived, so the news sequence has to clear
+// news_archive too.
+type serialSequence struct {
+	Table  string
+	Tables []string
+}
+
+var serialSequences = []serialSequence{
+	{Table: "news", Tables: []string{"news", "news_archive"}},
+	{Table: "topics", Tables: []string{"topics"}},
+	{Table: "collections", Tables: []string{"collections"}},
+	{Table: "moderation_terms", Tables: []string{"moderation_terms"}},
+	{Table: "moderation_flags", Tables: []string{"moderation_flags"}},
+	{Table: "users", Tables: []string{"users"}},
+}
+
+// serialTable returns the table whose primary key constraint is named
+// constraint, if its ids come from a sequence. A duplicate on one of
+// these means the sequence handed out an id that is already taken.
+func serialTable(constraint string) (string, bool) {
+	for _, s := range serialSequences {
+		if constraint == s.Table+"_pkey" {
+			return s.Table, true
+		}
+	}
+	return "", false
+}
+
+func (s serialSequence) maxIDSQL() string {
+	maxes := make([]string, len(s.Tables))
+	for i, table := range s.Tables {
+		maxes[i] = "(SELECT MAX(id) FROM " + table + ")"
+	}
+	return "COALESCE(GREATEST(" + strings.Join(maxes, ", ") + "), 0)"
+}
+
+// collidingSQL selects the ids at or above next, which inserts will run
+// into
+func (s serialSequence) collidingSQL(next int64) string {
+	selects := make([]string, len(s.Tables))
+	for i, table := range s.Tables {
+		selects[i] = "SELECT id FROM " + table + " WHERE id >= " + strconv.FormatInt(next, 10)
+	}
+	return strings.Join(selects, " UNION ALL ")
+}
+
+// sequenceStatus is where a sequence stands against the ids in use
+type sequenceStatus struct {
+	sequence string
+	nextID   int64
+	maxID    int64
+}
+
+func (st sequenceStatus) behind() bool {
+	return st.nextID <= st.maxID
+}
+
+// status reads the sequence without consuming a value. SERIAL sequences
+// step by one, and last_value is the next value until is_called is set.
+func (s serialSequence) status(ctx context.Context, q querier) (sequenceStatus, error) {
+	var st sequenceStatus
+	err := q.QueryRowContext(ctx, `SELECT pg_get_serial_sequence($1, 'id'), `+s.maxIDSQL(), s.Table).Scan(&st.sequence, &st.maxID)
+	if err != nil {
+		return st, err
+	}
+	// The name comes from the catalog already quoted
+	err = q.QueryRowContext(ctx, `SELECT CASE WHEN is_called THEN last_value + 1 ELSE last_value END FROM `+st.sequence).Scan(&st.nextID)
+	return st, err
+}
+
+func init() {
+	// setval ignores transactions, so a dry run could not roll a fix back;
+	// these only report, and /api/admin/sequences/repair fixes them
+	for _, s := range serialSequences {
+		registerIntegrityCheck(integrityCheck{
+			Name:        s.Table + "_sequence_behind",
+			Description: "Ids in " + strings.Join(s.Tables, " and ") + " at or above the next value of the " + s.Table + " id sequence, which new rows will collide with; repair with POST /api/admin/sequences/repair",
+			Find: func(ctx context.Context, q querier) (int64, []int, error) {
+				st, err := s.status(ctx, q)
+				if err != nil {
+					return 0, nil, err
+				}
+				return sqlFinder(s.collidingSQL(st.nextID))(ctx, q)
+			},
+		})
+	}
+}
+
+// warnSequencesBehind is the startup self-test for sequences left behind
+// by a restore or an import that kept its ids. Inserts into such a table
+// fail until the sequence is repaired, so it is logged loudly, but the
+// server still starts since reads are unaffected.
+func warnSequencesBehind(ctx context.Context) {
+	for _, s := range serialSequences {
+		st, err := s.status(ctx, db)
+		if err != nil {
+			slog.Warn("Could not check id sequence", "table", s.Table, "error", err)
+			continue
+		}
+		if st.behind() {
+			slog.Warn("Id sequence is behind existing rows, inserts will fail until POST /api/admin/sequences/repair",
+				"table", s.Table, "sequence", st.sequence, "next_id", st.nextID, "max_id", st.maxID)
+		}
+	}
+}
+
+// SequenceRepair is what repairing one sequence changed, or would have
+// changed in a dry run
+type SequenceRepair struct {
+	Table          string `json:"table"`
+	Sequence       string `json:"sequence"`
+	MaxID          int64  `json:"max_id"`
+	NextID         int64  `json:"next_id"`
+	PreviousNextID int64  `json:"previous_next_id"`
+	Repaired       bool   `json:"repaired"`
+	DryRun         bool   `json:"dry_run"`
+}
+
+// repairSequences advances every sequence that is behind, or those named
+// by ?table=, to one past the highest id in use. Sequences are only ever
+// moved forward. With ?dry_run=true nothing changes.
+func repairSequences(c echo.Context) error {
+	sequences := serialSequences
+	if names := c.QueryParams()["table"]; len(names) > 0 {
+		sequences = nil
+		for _, name := range names {
+			found := false
+			for _, s := range serialSequences {
+				if s.Table == name {
+					sequences = append(sequences, s)
+					found = true
+				}
+			}
+			if !found {
+				return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidParameter, Message: "Unknown table " + strconv.Quote(name)})
+			}
+		}
+	}
+	dryRun := false
+	if v := c.QueryParam("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Code: codeInvalidParameter, Message: "dry_run must be a boolean"})
+		}
+	}
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	repairs := []SequenceRepair{}
+	for _, s := range sequences {
+		repair, err := repairSequence(ctx, s, dryRun)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "Failed to repair the " + s.Table + " id sequence"})
+		}
+		if repair.Repaired && !dryRun {
+			requestLog(ctx).Info("Repaired id sequence", "table", s.Table, "previous_next_id", repair.PreviousNextID, "next_id", repair.NextID)
+		}
+		repairs = append(repairs, repair)
+	}
+	return respondList(c, repairs)
+}
+
+// repairSequence holds SHARE locks on the sequence's tables, which let
+// reads through but hold inserts back, so no id can be taken between
+// reading the maximum and moving the sequence past it
+func repairSequence(ctx context.Context, s serialSequence, dryRun bool) (SequenceRepair, error) {
+	repair := SequenceRepair{Table: s.Table, DryRun: dryRun}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return repair, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE "+strings.Join(s.Tables, ", ")+" IN SHARE MODE"); err != nil {
+		return repair, err
+	}
+	st, err := s.status(ctx, tx)
+	if err != nil {
+		return repair, err
+	}
+	repair.Sequence, repair.MaxID, repair.PreviousNextID, repair.NextID = st.sequence, st.maxID, st.nextID, st.nextID
+	if !st.behind() {
+		return repair, nil
+	}
+	repair.Repaired, repair.NextID = true, st.maxID+1
+	if dryRun {
+		return repair, nil
+	}
+	if _, err := tx.ExecContext(ctx, "SELECT setval($1, $2, false)", st.sequence, st.maxID+1); err != nil {
+		return repair, fmt.Errorf("setval: %w", err)
+	}
+	return repair, tx.Commit()
+}
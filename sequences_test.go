@@ -0,0 +1,96 @@
+// sequences_test.go
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerialTable(t *testing.T) {
+	table, ok := serialTable("news_pkey")
+	assert.True(t, ok)
+	assert.Equal(t, "news", table)
+	_, ok = serialTable("news_archive_pkey")
+	assert.False(t, ok, "archived rows keep their news id")
+	_, ok = serialTable("topics_name_key")
+	assert.False(t, ok)
+}
+
+// An import that keeps its ids leaves the sequence behind, so creates
+// fail with a pointer to the repair, which makes them succeed again
+func TestSequenceRepairAfterPreservedIDImport(t *testing.T) {
+	requirePostgres(t)
+	s := newTestServer(t)
+	topics := serialSequences[1]
+	name := uniqueName(t, "Imported")
+	defer db.Exec("DELETE FROM topics WHERE name LIKE $1", name+"%")
+
+	st, err := topics.status(context.Background(), db)
+	if !assert.NoError(t, err) {
+		return
+	}
+	next := st.nextID
+	_, err = db.Exec(`INSERT INTO topics (id, name, description) VALUES ($1, $2, ''), ($3, $4, ''), ($5, $6, '')`,
+		next, name+" 1", next+1, name+" 2", next+2, name+" 3")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var body ErrorResponse
+	if s.expect(s.do(http.MethodPost, "/api/topics", map[string]string{"name": name + " new"}), http.StatusInternalServerError, &body) {
+		assert.Equal(t, codeSequenceBehind, body.Code)
+		assert.Contains(t, body.Message, "POST /api/admin/sequences/repair")
+	}
+
+	var report []IntegrityResult
+	if s.expect(s.do(http.MethodGet, "/api/admin/integrity?check=topics_sequence_behind", nil), http.StatusOK, &report) && assert.Len(t, report, 1) {
+		assert.GreaterOrEqual(t, report[0].Violations, int64(1))
+		assert.Contains(t, report[0].SampleIDs, int(next+2))
+		assert.False(t, report[0].Repairable)
+	}
+
+	// A dry run reports the fix and leaves the sequence alone
+	var repairs []SequenceRepair
+	if s.expect(s.do(http.MethodPost, "/api/admin/sequences/repair?table=topics&dry_run=true", nil), http.StatusOK, &repairs) && assert.Len(t, repairs, 1) {
+		assert.True(t, repairs[0].Repaired)
+		assert.True(t, repairs[0].DryRun)
+		assert.Equal(t, repairs[0].MaxID+1, repairs[0].NextID)
+	}
+	s.expect(s.do(http.MethodPost, "/api/topics", map[string]string{"name": name + " new"}), http.StatusInternalServerError, nil)
+
+	if s.expect(s.do(http.MethodPost, "/api/admin/sequences/repair?table=topics", nil), http.StatusOK, &repairs) && assert.Len(t, repairs, 1) {
+		assert.True(t, repairs[0].Repaired)
+		assert.False(t, repairs[0].DryRun)
+		assert.GreaterOrEqual(t, repairs[0].MaxID, next+2)
+		assert.Equal(t, repairs[0].MaxID+1, repairs[0].NextID)
+	}
+
+	var created Topic
+	if s.expect(s.do(http.MethodPost, "/api/topics", map[string]string{"name": name + " new"}), http.StatusCreated, &created) {
+		assert.Equal(t, int(repairs[0].NextID), created.ID)
+	}
+
+	// Nothing is left to repair, and sequences never move backwards
+	if s.expect(s.do(http.MethodPost, "/api/admin/sequences/repair?table=topics", nil), http.StatusOK, &repairs) && assert.Len(t, repairs, 1) {
+		assert.False(t, repairs[0].Repaired)
+		assert.Greater(t, repairs[0].NextID, int64(created.ID))
+	}
+	if s.expect(s.do(http.MethodGet, "/api/admin/integrity?check=topics_sequence_behind", nil), http.StatusOK, &report) && assert.Len(t, report, 1) {
+		assert.Zero(t, report[0].Violations)
+	}
+}
+
+func TestSequenceRepairBadParameters(t *testing.T) {
+	requirePostgres(t)
+	s := newTestServer(t)
+	var body ErrorResponse
+	if s.expect(s.do(http.MethodPost, "/api/admin/sequences/repair?table=news_archive", nil), http.StatusBadRequest, &body) {
+		assert.Equal(t, codeInvalidParameter, body.Code)
+	}
+	if s.expect(s.do(http.MethodPost, "/api/admin/sequences/repair?dry_run=maybe", nil), http.StatusBadRequest, &body) {
+		assert.Equal(t, codeInvalidParameter, body.Code)
+	}
+}
@@ -0,0 +1,37 @@
+// serializer.go
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// bufferedJSONSerializer encodes the whole response before writing any of
+// it, so an encoding failure turns into a clean 500 instead of a 200 with
+// truncated JSON. encoding/json replaces invalid UTF-8 with U+FFFD both
+// when decoding request bodies and when encoding responses, so bad bytes
+// are repaired on the way in and cannot break output on the way out.
+type bufferedJSONSerializer struct {
+	echo.DefaultJSONSerializer
+}
+
+func (bufferedJSONSerializer) Serialize(c echo.Context, i interface{}, indent string) error {
+	i = withRequestID(c, i)
+	done := observe(c.Request().Context(), "render")
+	var body []byte
+	var err error
+	if indent != "" {
+		body, err = json.MarshalIndent(i, "", indent)
+	} else {
+		body, err = json.Marshal(i)
+	}
+	done()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to encode response").SetInternal(err)
+	}
+
+	_, err = c.Response().Write(append(body, '\n'))
+	return err
+}
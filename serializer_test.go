@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferedJSONSerializer(t *testing.T) {
+	e := echo.New()
+	e.JSONSerializer = bufferedJSONSerializer{}
+
+	// Invalid UTF-8 that slipped into stored data still yields valid JSON
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	err := e.NewContext(req, rec).JSON(http.StatusOK, News{ID: 1, Title: "bad \xff\xfe title"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, json.Valid(rec.Body.Bytes()))
+
+	var news News
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &news))
+	assert.Equal(t, "bad �� title", news.Title)
+
+	// An encoding failure leaves the response unwritten and becomes a 500
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	err = c.JSON(http.StatusOK, map[string]float64{"score": math.NaN()})
+	assert.False(t, c.Response().Committed)
+	assert.Empty(t, rec.Body.String())
+
+	e.HTTPErrorHandler(err, c)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.True(t, json.Valid(rec.Body.Bytes()))
+	assert.Contains(t, rec.Body.String(), "Failed to encode response")
+}
+
+func TestBindRepairsInvalidUTF8(t *testing.T) {
+	e := setupEcho()
+
+	body := `{"title":"Bad ` + "\xff" + `","content":"Body","topic_id":1}`
+	req := httptest.NewRequest(http.MethodPost, "/api/news", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	news := new(News)
+	assert.NoError(t, e.NewContext(req, httptest.NewRecorder()).Bind(news))
+	assert.Equal(t, "Bad \uFFFD", news.Title)
+}
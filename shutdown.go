@@ -0,0 +1,89 @@
+// shutdown.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultShutdownTimeout is how long in-flight requests may take to finish
+// after SIGINT or SIGTERM unless SHUTDOWN_TIMEOUT overrides it
+const defaultShutdownTimeout = 30 * time.Second
+
+// defaultReadinessGrace is how long /healthz/ready answers 503 after
+// SIGTERM, before the listener closes, unless READINESS_GRACE overrides it
+const defaultReadinessGrace = 5 * time.Second
+
+// shuttingDown is set when a shutdown signal arrives
+var shuttingDown atomic.Bool
+
+func loadShutdownTimeout() time.Duration {
+	v := os.Getenv("SHUTDOWN_TIMEOUT")
+	if v == "" {
+		return defaultShutdownTimeout
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		fatalf("Invalid SHUTDOWN_TIMEOUT %q: must be a positive duration", v)
+	}
+	return d
+}
+
+func loadReadinessGrace() time.Duration {
+	v := os.Getenv("READINESS_GRACE")
+	if v == "" {
+		return defaultReadinessGrace
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		fatalf("Invalid READINESS_GRACE %q: must be a non-negative duration", v)
+	}
+	return d
+}
+
+// serve runs e on addr until SIGINT or SIGTERM arrives, then stops
+// accepting connections and waits up to drain for in-flight requests.
+// After SIGTERM, which is how Kubernetes stops a pod, it first keeps
+// serving for grace with readiness failing, so load balancers stop
+// sending traffic before the listener closes; SIGINT skips the wait.
+// It returns early if the server fails to start.
+func serve(e *echo.Echo, addr string, grace, drain time.Duration) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	started := make(chan error, 1)
+	go func() { started <- e.Start(addr) }()
+
+	var sig os.Signal
+	select {
+	case err := <-started:
+		return err
+	case sig = <-signals:
+	}
+
+	shuttingDown.Store(true)
+	if sig == syscall.SIGTERM && grace > 0 {
+		slog.Info("Shutdown signal received, failing readiness before draining", "signal", sig.String(), "grace", grace.String())
+		time.Sleep(grace)
+		slog.Info("Draining requests", "timeout", drain.String())
+	} else {
+		slog.Info("Shutdown signal received, draining requests", "signal", sig.String(), "timeout", drain.String())
+	}
+	begin := time.Now()
+	drainCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if err := e.Shutdown(drainCtx); err != nil {
+		return fmt.Errorf("draining requests: %w", err)
+	}
+	slog.Info("Drained in-flight requests", "took", time.Since(begin).Round(time.Millisecond).String())
+	return nil
+}
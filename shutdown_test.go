@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// A request in flight when SIGTERM arrives still completes
+func TestServeDrainsOnSIGTERM(t *testing.T) {
+	t.Cleanup(func() { shuttingDown.Store(false) })
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+
+	inFlight := make(chan struct{})
+	e.GET("/slow", func(c echo.Context) error {
+		close(inFlight)
+		time.Sleep(300 * time.Millisecond)
+		return c.String(http.StatusOK, "done")
+	})
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- serve(e, "127.0.0.1:0", 0, 5*time.Second) }()
+
+	var addr string
+	for i := 0; i < 100 && addr == ""; i++ {
+		if a := e.ListenerAddr(); a != nil {
+			addr = a.String()
+		} else {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if !assert.NotEmpty(t, addr, "server did not start") {
+		return
+	}
+
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+
+	<-inFlight
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+
+	assert.Equal(t, http.StatusOK, <-status)
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after draining")
+	}
+}
+
+// After SIGTERM readiness fails while the listener is still open
+func TestServeFailsReadinessDuringGrace(t *testing.T) {
+	t.Cleanup(func() { shuttingDown.Store(false) })
+	schemaReady.Store(true)
+	t.Cleanup(func() { schemaReady.Store(false) })
+	saved := db
+	db = nil
+	t.Cleanup(func() { db = saved })
+
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.GET("/healthz/ready", readinessProbe)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- serve(e, "127.0.0.1:0", 500*time.Millisecond, 5*time.Second) }()
+
+	var addr string
+	for i := 0; i < 100 && addr == ""; i++ {
+		if a := e.ListenerAddr(); a != nil {
+			addr = a.String()
+		} else {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if !assert.NotEmpty(t, addr, "server did not start") {
+		return
+	}
+
+	ready := func() int {
+		resp, err := http.Get("http://" + addr + "/healthz/ready")
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusOK, ready())
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+	for i := 0; i < 20 && !shuttingDown.Load(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, ready())
+
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after the grace period")
+	}
+}
@@ -0,0 +1,73 @@
+// slug.go
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/text/unicode/norm"
+)
+
+// transliterations covers Latin letters that compatibility decomposition
+// does not reduce to ASCII
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d",
+	'ð': "d", 'ł': "l", 'þ': "th", 'ı': "i",
+}
+
+// slugify lowercases s and joins its ASCII letters and digits with single
+// hyphens. Accented and compatibility characters are transliterated first
+// (résumé → resume, ２０２４ → 2024); anything else, such as CJK or emoji,
+// acts as a separator. A title with nothing left gets a generic slug with
+// a hash suffix so distinct titles stay distinct.
+func slugify(s string) string {
+	var b strings.Builder
+	hyphen := false
+	emit := func(r rune) {
+		if hyphen && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteRune(r)
+		hyphen = false
+	}
+
+	for _, r := range norm.NFKD.String(strings.ToLower(s)) {
+		if t, ok := transliterations[r]; ok {
+			for _, tr := range t {
+				emit(tr)
+			}
+		} else if ('a' <= r && r <= 'z') || ('0' <= r && r <= '9') {
+			emit(r)
+		} else if !unicode.Is(unicode.Mn, r) {
+			// Combining marks split off by NFKD are dropped in place
+			hyphen = true
+		}
+	}
+
+	if b.Len() == 0 {
+		sum := sha256.Sum256([]byte(s))
+		return "untitled-" + hex.EncodeToString(sum[:4])
+	}
+	return b.String()
+}
+
+// validSlug reports whether s is already in the form slugify produces,
+// which rules out path separators, control characters and non-ASCII
+func validSlug(s string) bool {
+	return s != "" && slugify(s) == s
+}
+
+// slugParam returns the :slug path parameter percent-decoded. Echo only
+// decodes paths that use the default encoding, so %61bc or %2F arrive
+// still escaped.
+func slugParam(c echo.Context) string {
+	slug := c.Param("slug")
+	if decoded, err := url.PathUnescape(slug); err == nil {
+		return decoded
+	}
+	return slug
+}
@@ -0,0 +1,81 @@
+// slug_test.go
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var fallbackSlug = regexp.MustCompile(`^untitled-[0-9a-f]{8}$`)
+
+func TestSlugify(t *testing.T) {
+	// An empty want means the title normalizes to nothing and must get
+	// the hash-suffixed fallback
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"The Panama Papers", "the-panama-papers"},
+		{"  Part 1: The Beginning ", "part-1-the-beginning"},
+		{"already-a-slug", "already-a-slug"},
+		{"Multiple   ---  gaps", "multiple-gaps"},
+		{"Pemilu 2024: Hasil résumé — 日本", "pemilu-2024-hasil-resume"},
+		{"Ça va? Über Größe", "ca-va-uber-grosse"},
+		{"Smørrebrød og Æbleskiver", "smorrebrod-og-aebleskiver"},
+		{"Łódź Święto", "lodz-swieto"},
+		{"ｆｕｌｌｗｉｄｔｈ ２０２４", "fullwidth-2024"},
+		{"ﬁnal oﬀer", "final-offer"},
+		{"Crème brûlée 🍮 recipe", "creme-brulee-recipe"},
+		{"Tokyo 東京 Olympics", "tokyo-olympics"},
+		{"path/../traversal\x00\n", "path-traversal"},
+		{"日本語のニュース", ""},
+		{"🚀🔥💯", ""},
+		{"?!... —— ///", ""},
+	}
+	for _, tc := range cases {
+		got := slugify(tc.in)
+		if tc.want == "" {
+			assert.Regexp(t, fallbackSlug, got, "slugify(%q)", tc.in)
+		} else {
+			assert.Equal(t, tc.want, got, "slugify(%q)", tc.in)
+		}
+		assert.True(t, validSlug(got), "slugify(%q) = %q is not a valid slug", tc.in, got)
+	}
+
+	// The fallback is stable per title and differs between titles
+	assert.Equal(t, slugify("日本"), slugify("日本"))
+	assert.NotEqual(t, slugify("日本"), slugify("中国"))
+}
+
+func TestValidSlug(t *testing.T) {
+	assert.True(t, validSlug("the-panama-papers"))
+	assert.False(t, validSlug(""))
+	assert.False(t, validSlug("Upper-Case"))
+	assert.False(t, validSlug("double--hyphen"))
+	assert.False(t, validSlug("-leading"))
+	assert.False(t, validSlug("with/slash"))
+	assert.False(t, validSlug(`back\slash`))
+	assert.False(t, validSlug("tab\there"))
+	assert.False(t, validSlug("résumé"))
+}
+
+func TestSlugParam(t *testing.T) {
+	e := echo.New()
+	cases := map[string]string{
+		"plain-slug": "plain-slug",
+		"%61bc":      "abc",
+		"a%2Fb":      "a/b",
+		"bad%zz":     "bad%zz",
+	}
+	for raw, want := range cases {
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+		c.SetParamNames("slug")
+		c.SetParamValues(raw)
+		assert.Equal(t, want, slugParam(c), "slugParam(%q)", raw)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSort(t *testing.T) {
+	e := setupEcho()
+	cases := []struct {
+		query string
+		want  Sort
+		ok    bool
+	}{
+		{"", defaultNewsSort, true},
+		{"order=asc", Sort{Column: "n.created_at"}, true},
+		{"sort=title", Sort{Column: "n.title"}, true},
+		{"sort=updated_at&order=desc", Sort{Column: "n.updated_at", Desc: true}, true},
+		{"sort=created_at&order=desc", defaultNewsSort, true},
+		{"sort=content", Sort{}, false},
+		{"sort=n.title", Sort{}, false},
+		{"sort=title%3BDROP%20TABLE%20news", Sort{}, false},
+		{"sort=title&order=sideways", Sort{}, false},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/news?"+tc.query, nil)
+		s, err := parseSort(e.NewContext(req, httptest.NewRecorder()), newsSortColumns, defaultNewsSort)
+		if tc.ok {
+			assert.NoError(t, err, tc.query)
+			assert.Equal(t, tc.want, s, tc.query)
+		} else {
+			assert.Error(t, err, tc.query)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/topics?sort=title", nil)
+	_, err := parseSort(e.NewContext(req, httptest.NewRecorder()), topicSortColumns, Sort{Column: "name"})
+	assert.EqualError(t, err, "Sort must be one of created_at, name")
+}
+
+func TestSortOrderBy(t *testing.T) {
+	assert.Equal(t, "n.created_at DESC, n.id DESC", defaultNewsSort.orderBy("n.id"))
+	assert.Equal(t, "name ASC, id ASC", Sort{Column: "name"}.orderBy("id"))
+}
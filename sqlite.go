@@ -0,0 +1,99 @@
+// sqlite.go
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDialect stores news and topics in a single SQLite file. Column
+// lengths are CHECK constraints named *_length, since SQLite ignores
+// VARCHAR limits; timestamps are written by the application, never by a
+// column default.
+var sqliteDialect = dialect{
+	name:    "sqlite",
+	driver:  "sqlite3",
+	schemes: []string{"sqlite"},
+	dsn:     sqliteDSN,
+	schema: []string{
+		`CREATE TABLE IF NOT EXISTS topics (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			uuid TEXT NOT NULL UNIQUE,
+			name TEXT NOT NULL UNIQUE CONSTRAINT topics_name_length CHECK (length(name) <= 100),
+			description TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS news (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			uuid TEXT NOT NULL UNIQUE,
+			title TEXT NOT NULL CONSTRAINT news_title_length CHECK (length(title) <= 200),
+			content TEXT NOT NULL,
+			topic_id INTEGER REFERENCES topics(id) ON DELETE CASCADE,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS news_topic_id_created_at_idx ON news (topic_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS news_created_at_id_idx ON news (created_at, id)`,
+	},
+	columns: []sqlColumn{
+		{"topics", "long_description", "TEXT NOT NULL DEFAULT '' CONSTRAINT topics_long_description_length CHECK (length(long_description) <= 20000)"},
+		{"topics", "meta_title", "TEXT NOT NULL DEFAULT '' CONSTRAINT topics_meta_title_length CHECK (length(meta_title) <= 70)"},
+		{"topics", "meta_description", "TEXT NOT NULL DEFAULT '' CONSTRAINT topics_meta_description_length CHECK (length(meta_description) <= 160)"},
+		{"topics", "header_image_url", "TEXT NOT NULL DEFAULT '' CONSTRAINT topics_header_image_url_length CHECK (length(header_image_url) <= 2048)"},
+		{"news", "created_by", "INTEGER"},
+	},
+	translate: sqliteError,
+}
+
+// sqlitePath returns the file named by a sqlite:// DATABASE_URL, e.g.
+// sqlite://news.db or sqlite:///var/lib/news.db
+func sqlitePath(url string) (path string, ok bool) {
+	return strings.CutPrefix(url, "sqlite://")
+}
+
+// sqliteDSN is the driver DSN for a sqlite:// URL; the file is created if
+// missing. Foreign keys are off by default in SQLite, and transactions
+// take the write lock up front so two writers queue on the busy timeout
+// rather than failing mid-transaction.
+func sqliteDSN(url string) (string, error) {
+	path, _ := sqlitePath(url)
+	if path == "" {
+		return "", errors.New("sqlite:// URL has no file path")
+	}
+	return "file:" + path + "?_foreign_keys=on&_busy_timeout=5000&_txlock=immediate", nil
+}
+
+// sqliteError maps constraint failures to the errors storeError gives for
+// their Postgres equivalents
+func sqliteError(err error) error {
+	var se sqlite3.Error
+	if !errors.As(err, &se) {
+		return err
+	}
+	switch se.ExtendedCode {
+	case sqlite3.ErrConstraintUnique:
+		// "UNIQUE constraint failed: topics.name"
+		field := se.Error()
+		if i := strings.LastIndex(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+		return &DuplicateError{Field: field}
+	case sqlite3.ErrConstraintForeignKey:
+		// news.topic_id is the only reference
+		return &ForeignKeyError{Ref: "topic"}
+	case sqlite3.ErrConstraintNotNull:
+		return &InvalidValueError{Problem: "required"}
+	case sqlite3.ErrConstraintCheck:
+		if strings.HasSuffix(se.Error(), "_length") {
+			return &InvalidValueError{Problem: "too long"}
+		}
+		return &InvalidValueError{Problem: "not allowed"}
+	}
+	if se.Code == sqlite3.ErrBusy || se.Code == sqlite3.ErrLocked {
+		return ErrConflict
+	}
+	return err
+}
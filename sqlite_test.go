@@ -0,0 +1,95 @@
+// sqlite_test.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// openTestSQLite opens a SQLite store at path, closed when the test ends
+func openTestSQLite(t *testing.T, path string) *sqlStore {
+	dsn, err := sqliteDSN("sqlite://" + path)
+	if err != nil {
+		t.Fatalf("sqlite DSN: %v", err)
+	}
+	s, err := openSQLStore(sqliteDialect, dsn, poolSettings{MaxOpenConns: 4, MaxIdleConns: 4})
+	if err != nil {
+		t.Fatalf("opening SQLite: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLitePath(t *testing.T) {
+	for url, want := range map[string]string{
+		"sqlite://news.db":           "news.db",
+		"sqlite:///var/lib/news.db":  "/var/lib/news.db",
+		"sqlite://./data/dev.sqlite": "./data/dev.sqlite",
+	} {
+		path, ok := sqlitePath(url)
+		assert.True(t, ok, url)
+		assert.Equal(t, want, path)
+	}
+	_, ok := sqlitePath("postgres://localhost/newsdb")
+	assert.False(t, ok)
+	_, err := sqliteDSN("sqlite://")
+	assert.Error(t, err)
+}
+
+// Reopening an existing file keeps its rows and leaves the schema alone
+func TestSQLiteReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "news.db")
+	ctx := context.Background()
+
+	first := openTestSQLite(t, path)
+	topic, err := first.CreateTopic(ctx, Topic{Name: "Kept"})
+	assert.NoError(t, err)
+	first.Close()
+
+	second := openTestSQLite(t, path)
+	got, err := second.GetTopic(ctx, topic.UUID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, topic, got)
+	}
+	_, err = second.CreateTopic(ctx, Topic{Name: "Kept"})
+	assert.Equal(t, &DuplicateError{Field: "name"}, err)
+}
+
+// A file created before the landing page columns gets them on open, with
+// its topics kept
+func TestSQLiteAddsColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "news.db")
+	old, err := sql.Open("sqlite3", "file:"+path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = old.Exec(`CREATE TABLE topics (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		uuid TEXT NOT NULL UNIQUE,
+		name TEXT NOT NULL UNIQUE,
+		description TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	)`)
+	assert.NoError(t, err)
+	_, err = old.Exec(`INSERT INTO topics (uuid, name, description, created_at, updated_at)
+		VALUES ('0b7ab2a8-4f31-4c5e-9b61-0f6b0d3f1c11', 'Old', 'Before metadata', '2024-01-01 00:00:00', '2024-01-01 00:00:00')`)
+	assert.NoError(t, err)
+	old.Close()
+
+	s := openTestSQLite(t, path)
+	got, err := s.GetTopic(context.Background(), "0b7ab2a8-4f31-4c5e-9b61-0f6b0d3f1c11")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Before metadata", got.Tagline)
+		assert.Empty(t, got.MetaTitle)
+	}
+	title := "Old news"
+	got, err = s.UpdateTopic(context.Background(), got.UUID, TopicChange{MetaTitle: &title})
+	if assert.NoError(t, err) {
+		assert.Equal(t, title, got.MetaTitle)
+	}
+}
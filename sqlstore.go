@@ -0,0 +1,464 @@
+// sqlstore.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// dialect is what sqlStore needs to know about a database other than
+// Postgres. Queries are written in the SQL they all share, with ?
+// placeholders bound by position.
+type dialect struct {
+	name   string
+	driver string
+	// schemes are the DATABASE_URL schemes that select the dialect
+	schemes []string
+	// dsn turns such a DATABASE_URL into the driver's data source name
+	dsn func(url string) (string, error)
+	// schema creates the topics and news tables if they are missing
+	schema []string
+	// columns were added after the tables were first released;
+	// openSQLStore adds any an existing database lacks
+	columns []sqlColumn
+	// translate maps driver errors to the store errors in errors.go
+	translate func(error) error
+}
+
+// sqlColumn is a column definition as ALTER TABLE ADD COLUMN takes it
+type sqlColumn struct {
+	table, name, definition string
+}
+
+// sqlDialects are tried in order by findDialect
+var sqlDialects = []dialect{sqliteDialect, mysqlDialect}
+
+// findDialect returns the dialect whose scheme DATABASE_URL uses. ok is
+// false for Postgres URLs, which pgStore serves.
+func findDialect(url string) (d dialect, ok bool) {
+	for _, d := range sqlDialects {
+		for _, scheme := range d.schemes {
+			if strings.HasPrefix(url, scheme+"://") {
+				return d, true
+			}
+		}
+	}
+	return dialect{}, false
+}
+
+// sqlStore is the Store for SQLite and MySQL. It covers news and topics
+// only: there is no archive, moderation flags are not kept, and
+// routes outside the Store answer 501 as they do with in-memory storage.
+// UUIDs are generated here, lower case, since there is no
+// gen_random_uuid.
+type sqlStore struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+// openSQLStore connects with the pool settings from the environment and
+// creates the schema
+func openSQLStore(d dialect, dsn string, pool poolSettings) (*sqlStore, error) {
+	conn, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	pool.apply(conn)
+	for _, stmt := range d.schema {
+		if _, err := conn.Exec(stmt); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	for _, col := range d.columns {
+		if err := addColumn(conn, col); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return &sqlStore{db: conn, dialect: d}, nil
+}
+
+// addColumn adds col unless the table has it already. Neither SQLite nor
+// MySQL takes ADD COLUMN IF NOT EXISTS, so it probes with a query that
+// fails only for a missing column.
+func addColumn(conn *sql.DB, col sqlColumn) error {
+	if _, err := conn.Exec("SELECT " + col.name + " FROM " + col.table + " WHERE 1 = 0"); err == nil {
+		return nil
+	}
+	_, err := conn.Exec("ALTER TABLE " + col.table + " ADD COLUMN " + col.name + " " + col.definition)
+	return err
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// err translates a driver error, then applies the common mapping
+func (s *sqlStore) err(err error) error {
+	if err == nil {
+		return nil
+	}
+	return storeError(s.dialect.translate(err))
+}
+
+// key is a path id as stored: UUIDs are kept lower case
+func key(id string) string {
+	if isUUID(id) {
+		return strings.ToLower(id)
+	}
+	return id
+}
+
+// Listing source aliased n, shaped like liveNewsSource
+const sqlNewsSource = `(SELECT id, uuid, title, content, topic_id, created_at, updated_at, created_by, FALSE AS archived FROM news) n`
+
+const sqlNewsColumns = "id, uuid, title, content, topic_id, created_at, updated_at, created_by"
+
+// topicSelectColumns are what scanTopic reads, in every store's schema.
+// The description column holds the tagline.
+const topicSelectColumns = "id, uuid, name, description, long_description, meta_title, meta_description, header_image_url, created_at, updated_at"
+
+func scanNews(row rowScanner) (News, error) {
+	var n News
+	err := row.Scan(&n.ID, &n.UUID, &n.Title, &n.Content, &n.TopicID, &n.CreatedAt, &n.UpdatedAt, &n.CreatedBy)
+	return n, err
+}
+
+func scanTopic(row rowScanner) (Topic, error) {
+	var t Topic
+	err := row.Scan(&t.ID, &t.UUID, &t.Name, &t.Tagline, &t.LongDescription, &t.MetaTitle, &t.MetaDescription, &t.HeaderImageURL, &t.CreatedAt, &t.UpdatedAt)
+	return t, err
+}
+
+func (s *sqlStore) GetNews(ctx context.Context, id string, fields []string, includeTopic bool) (interface{}, error) {
+	sel := newsSelect{fields: fields, includeTopic: includeTopic}
+	news, _, err := sel.scan(s.db.QueryRowContext(ctx, `
+		SELECT `+sel.columns()+`
+		FROM `+sqlNewsSource+sel.join()+`
+		WHERE n.`+idColumn(id)+` = ?
+	`, key(id)))
+	if err != nil {
+		return nil, s.err(err)
+	}
+	return news, nil
+}
+
+// filter adds q's conditions on n to w, as NewsQuery.filter does with
+// the Postgres-only ILIKE and ANY replaced
+func (s *sqlStore) filter(q NewsQuery, w *where) {
+	if q.Topic != "" {
+		w.add("n.topic_id = (SELECT id FROM topics WHERE "+idColumn(q.Topic)+" = ?)", key(q.Topic))
+	}
+	if !q.Dates.From.IsZero() {
+		w.add("n.created_at >= ?", q.Dates.From.UTC())
+	}
+	if !q.Dates.Until.IsZero() {
+		w.add("n.created_at < ?", q.Dates.Until.UTC())
+	}
+	if q.Search != "" {
+		// ! rather than \ as the escape, which MySQL would read as a
+		// string escape
+		pattern := "%" + strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(strings.ToLower(q.Search)) + "%"
+		w.add(`(LOWER(n.title) LIKE ? ESCAPE '!' OR LOWER(n.content) LIKE ? ESCAPE '!')`, pattern, pattern)
+	}
+	if q.TopicIDs != nil {
+		if len(q.TopicIDs) == 0 {
+			w.add("1 = 0")
+			return
+		}
+		args := make([]interface{}, len(q.TopicIDs))
+		for i, id := range q.TopicIDs {
+			args[i] = id
+		}
+		w.add("n.topic_id IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")+")", args...)
+	}
+}
+
+// ListNews follows pgStore.ListNews: one where for the count and the
+// page, and one extra row to detect a next page
+func (s *sqlStore) ListNews(ctx context.Context, q NewsQuery, fields []string, withTotal bool) ([]interface{}, ListMeta, error) {
+	var meta ListMeta
+	w := where{positional: true}
+	s.filter(q, &w)
+
+	if withTotal {
+		var total int64
+		err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+sqlNewsSource+" "+w.String(), w.args...).Scan(&total)
+		if err != nil {
+			return nil, meta, s.err(err)
+		}
+		meta.Total = &total
+	}
+
+	page := q.Page
+	if page.After != nil {
+		w.add("(n.created_at, n.id) < (?, ?)", page.After.CreatedAt.UTC(), page.After.ID)
+	}
+
+	sel := newsSelect{fields: fields, includeTopic: q.IncludeTopic, cursor: q.keyset()}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+sel.columns()+`
+		FROM `+sqlNewsSource+sel.join()+`
+		`+w.String()+`
+		ORDER BY `+q.Sort.orderBy("n.id")+`
+		LIMIT `+w.arg(page.Limit+1)+` OFFSET `+w.arg(page.Offset), w.args...)
+	if err != nil {
+		return nil, meta, s.err(err)
+	}
+	defer rows.Close()
+
+	var items []interface{}
+	var positions []newsCursor
+	for rows.Next() {
+		item, pos, err := sel.scan(rows)
+		if err != nil {
+			return nil, meta, s.err(err)
+		}
+		items = append(items, item)
+		positions = append(positions, pos)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, meta, s.err(err)
+	}
+
+	if len(items) > page.Limit {
+		items = items[:page.Limit]
+		if q.keyset() {
+			meta.NextCursor = positions[page.Limit-1].encode()
+		}
+	}
+	return items, meta, nil
+}
+
+// resolveTopic finds the article's topic by id or name, creating the name
+// when inline creation is enabled
+func (s *sqlStore) resolveTopic(ctx context.Context, tx *sql.Tx, req CreateNewsRequest) (Topic, error) {
+	if req.TopicName == "" {
+		return scanTopic(tx.QueryRowContext(ctx, "SELECT "+topicSelectColumns+" FROM topics WHERE id = ?", req.TopicID))
+	}
+	topic, err := scanTopic(tx.QueryRowContext(ctx, "SELECT "+topicSelectColumns+" FROM topics WHERE name = ?", req.TopicName))
+	if err != sql.ErrNoRows || !inlineTopicCreation {
+		return topic, err
+	}
+	return s.insertTopic(ctx, tx, Topic{Name: req.TopicName})
+}
+
+// CreateNews writes the topic if needed and the article in one
+// transaction. Ids come from LastInsertId; everything else is known
+// before the insert.
+func (s *sqlStore) CreateNews(ctx context.Context, req CreateNewsRequest, verdict ModerationResult) (News, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return News{}, s.err(err)
+	}
+	defer tx.Rollback()
+
+	topic, err := s.resolveTopic(ctx, tx, req)
+	if err == sql.ErrNoRows {
+		return News{}, &ForeignKeyError{Ref: "topic"}
+	} else if err != nil {
+		return News{}, s.err(err)
+	}
+
+	now := timestamp()
+	created := News{UUID: newUUID(), Title: req.Title, Content: req.Content, TopicID: topic.ID, CreatedAt: now, UpdatedAt: now, CreatedBy: req.CreatedBy, Topic: topic.summary()}
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO news (uuid, title, content, topic_id, created_at, updated_at, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, created.UUID, created.Title, created.Content, created.TopicID, now, now, created.CreatedBy)
+	if err != nil {
+		return News{}, s.err(err)
+	}
+	if created.ID, err = insertedID(res); err != nil {
+		return News{}, s.err(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return News{}, s.err(err)
+	}
+	return created, nil
+}
+
+func insertedID(res sql.Result) (int, error) {
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// UpdateNews reads the row, applies change and writes it back in one
+// transaction, moving updated_at by the same rule as patchSet
+func (s *sqlStore) UpdateNews(ctx context.Context, id string, change NewsChange, verdict ModerationResult) (News, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return News{}, s.err(err)
+	}
+	defer tx.Rollback()
+
+	if change.TopicID != nil {
+		var topicExists bool
+		err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM topics WHERE id = ?)", *change.TopicID).Scan(&topicExists)
+		if err != nil {
+			return News{}, s.err(err)
+		}
+		if !topicExists {
+			return News{}, &ForeignKeyError{Ref: "topic"}
+		}
+	}
+
+	news, err := scanNews(tx.QueryRowContext(ctx, "SELECT "+sqlNewsColumns+" FROM news WHERE "+idColumn(id)+" = ?", key(id)))
+	if err != nil {
+		return News{}, s.err(err)
+	}
+	before := news
+	if change.Title != nil {
+		news.Title = *change.Title
+	}
+	if change.Content != nil {
+		news.Content = *change.Content
+	}
+	if change.TopicID != nil {
+		news.TopicID = *change.TopicID
+	}
+	if change.Replace || news != before {
+		news.UpdatedAt = timestamp()
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE news
+		SET title = ?, content = ?, topic_id = ?, updated_at = ?
+		WHERE id = ?
+	`, news.Title, news.Content, news.TopicID, news.UpdatedAt, news.ID)
+	if err != nil {
+		return News{}, s.err(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return News{}, s.err(err)
+	}
+	return news, nil
+}
+
+func (s *sqlStore) DeleteNews(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM news WHERE "+idColumn(id)+" = ?", key(id))
+	return s.affected(res, err)
+}
+
+// affected returns ErrNotFound when an update or delete matched no row
+func (s *sqlStore) affected(res sql.Result, err error) error {
+	if err != nil {
+		return s.err(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return s.err(err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *sqlStore) ListTopics(ctx context.Context, order Sort) ([]Topic, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+topicSelectColumns+" FROM topics ORDER BY "+order.orderBy("id"))
+	if err != nil {
+		return nil, s.err(err)
+	}
+	defer rows.Close()
+
+	var topics []Topic
+	for rows.Next() {
+		topic, err := scanTopic(rows)
+		if err != nil {
+			return nil, s.err(err)
+		}
+		topics = append(topics, topic)
+	}
+	return topics, s.err(rows.Err())
+}
+
+func (s *sqlStore) GetTopic(ctx context.Context, id string) (Topic, error) {
+	topic, err := scanTopic(s.db.QueryRowContext(ctx, "SELECT "+topicSelectColumns+" FROM topics WHERE "+idColumn(id)+" = ?", key(id)))
+	return topic, s.err(err)
+}
+
+func (s *sqlStore) CreateTopic(ctx context.Context, topic Topic) (Topic, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Topic{}, s.err(err)
+	}
+	defer tx.Rollback()
+
+	created, err := s.insertTopic(ctx, tx, topic)
+	if err != nil {
+		return Topic{}, s.err(err)
+	}
+	return created, s.err(tx.Commit())
+}
+
+func (s *sqlStore) insertTopic(ctx context.Context, tx *sql.Tx, topic Topic) (Topic, error) {
+	now := timestamp()
+	created := Topic{
+		UUID:            newUUID(),
+		Name:            topic.Name,
+		Tagline:         topic.Tagline,
+		LongDescription: topic.LongDescription,
+		MetaTitle:       topic.MetaTitle,
+		MetaDescription: topic.MetaDescription,
+		HeaderImageURL:  topic.HeaderImageURL,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO topics (uuid, name, description, long_description, meta_title, meta_description, header_image_url, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, created.UUID, created.Name, created.Tagline, created.LongDescription, created.MetaTitle, created.MetaDescription, created.HeaderImageURL, now, now)
+	if err != nil {
+		return Topic{}, s.err(err)
+	}
+	created.ID, err = insertedID(res)
+	return created, s.err(err)
+}
+
+func (s *sqlStore) UpdateTopic(ctx context.Context, id string, change TopicChange) (Topic, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Topic{}, s.err(err)
+	}
+	defer tx.Rollback()
+
+	topic, err := scanTopic(tx.QueryRowContext(ctx, "SELECT "+topicSelectColumns+" FROM topics WHERE "+idColumn(id)+" = ?", key(id)))
+	if err != nil {
+		return Topic{}, s.err(err)
+	}
+	before := topic
+	change.apply(&topic)
+	if change.Replace || topic != before {
+		topic.UpdatedAt = timestamp()
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE topics
+		SET name = ?, description = ?, long_description = ?, meta_title = ?, meta_description = ?, header_image_url = ?, updated_at = ?
+		WHERE id = ?
+	`, topic.Name, topic.Tagline, topic.LongDescription, topic.MetaTitle, topic.MetaDescription, topic.HeaderImageURL, topic.UpdatedAt, topic.ID)
+	if err != nil {
+		return Topic{}, s.err(err)
+	}
+	return topic, s.err(tx.Commit())
+}
+
+func (s *sqlStore) DeleteTopic(ctx context.Context, id string) error {
+	var count int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM news
+		WHERE topic_id = (SELECT id FROM topics WHERE `+idColumn(id)+` = ?)
+	`, key(id)).Scan(&count)
+	if err != nil {
+		return s.err(err)
+	}
+	if count > 0 {
+		return ErrInUse
+	}
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM topics WHERE "+idColumn(id)+" = ?", key(id))
+	return s.affected(res, err)
+}
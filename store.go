@@ -0,0 +1,108 @@
+// store.go
+package main
+
+import (
+	"context"
+	"os"
+)
+
+// Store is the persistence behind the news and topic handlers. Methods
+// return the typed errors from errors.go (ErrNotFound, *DuplicateError,
+// ...) rather than driver errors, so handlers map them with respondError
+// and a fake needs no Postgres. Ids are the raw path values, an integer
+// id or a UUID, as accepted by validID.
+type Store interface {
+	// GetNews returns one article, live or archived. fields selects a
+	// sparse map instead of the full News; includeTopic embeds its topic.
+	GetNews(ctx context.Context, id string, fields []string, includeTopic bool) (interface{}, error)
+	// ListNews returns one page of q with its meta. withTotal asks for the
+	// total row count, which return=minimal listings skip.
+	ListNews(ctx context.Context, q NewsQuery, fields []string, withTotal bool) ([]interface{}, ListMeta, error)
+	// CreateNews writes the article, creating its topic by name if needed,
+	// and records a flag verdict alongside it. A missing topic is a
+	// *ForeignKeyError for "topic".
+	CreateNews(ctx context.Context, req CreateNewsRequest, verdict ModerationResult) (News, error)
+	// UpdateNews applies change and records a flag verdict. It returns
+	// ErrArchived for articles that have been moved to the archive.
+	UpdateNews(ctx context.Context, id string, change NewsChange, verdict ModerationResult) (News, error)
+	// DeleteNews removes a live or archived article and its collection
+	// memberships
+	DeleteNews(ctx context.Context, id string) error
+
+	ListTopics(ctx context.Context, order Sort) ([]Topic, error)
+	GetTopic(ctx context.Context, id string) (Topic, error)
+	// CreateTopic writes the name and landing page fields of topic
+	CreateTopic(ctx context.Context, topic Topic) (Topic, error)
+	UpdateTopic(ctx context.Context, id string, change TopicChange) (Topic, error)
+	// DeleteTopic returns ErrInUse while any article, archived ones
+	// included, still belongs to the topic
+	DeleteTopic(ctx context.Context, id string) error
+}
+
+// NewsChange lists the fields an update writes; nil keeps a field.
+// updated_at only moves when a value differs, unless Replace is set as it
+// is for PUT.
+type NewsChange struct {
+	Title   *string
+	Content *string
+	TopicID *int
+	Replace bool
+}
+
+// TopicChange is the topic equivalent of NewsChange
+type TopicChange struct {
+	Name            *string
+	Tagline         *string
+	LongDescription *string
+	MetaTitle       *string
+	MetaDescription *string
+	HeaderImageURL  *string
+	Replace         bool
+}
+
+// fields maps the limited fields of the change for topicFieldProblems
+func (c TopicChange) fields() map[string]*string {
+	return map[string]*string{
+		"tagline":          c.Tagline,
+		"long_description": c.LongDescription,
+		"meta_title":       c.MetaTitle,
+		"meta_description": c.MetaDescription,
+		"header_image_url": c.HeaderImageURL,
+	}
+}
+
+// apply writes the change to t, for stores that update a loaded copy
+func (c TopicChange) apply(t *Topic) {
+	for _, f := range []struct {
+		value *string
+		dest  *string
+	}{
+		{c.Name, &t.Name},
+		{c.Tagline, &t.Tagline},
+		{c.LongDescription, &t.LongDescription},
+		{c.MetaTitle, &t.MetaTitle},
+		{c.MetaDescription, &t.MetaDescription},
+		{c.HeaderImageURL, &t.HeaderImageURL},
+	} {
+		if f.value != nil {
+			*f.dest = *f.value
+		}
+	}
+}
+
+// Active store; main and the test harness install the Postgres one
+var store Store
+
+// memoryStorage reports whether STORAGE selects the in-memory store.
+// postgres, the default, is the only other value.
+func memoryStorage() bool {
+	switch v := os.Getenv("STORAGE"); v {
+	case "", "postgres":
+		return false
+	case "memory":
+		return true
+	default:
+		fatalf("Invalid STORAGE %q: must be postgres or memory", v)
+		return false
+	}
+}
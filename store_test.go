@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubStore fails every call with err, so handlers can be exercised
+// without Postgres
+type stubStore struct {
+	err error
+}
+
+func (s stubStore) GetNews(context.Context, string, []string, bool) (interface{}, error) {
+	return nil, s.err
+}
+
+func (s stubStore) ListNews(context.Context, NewsQuery, []string, bool) ([]interface{}, ListMeta, error) {
+	return nil, ListMeta{}, s.err
+}
+
+func (s stubStore) CreateNews(context.Context, CreateNewsRequest, ModerationResult) (News, error) {
+	return News{}, s.err
+}
+
+func (s stubStore) UpdateNews(context.Context, string, NewsChange, ModerationResult) (News, error) {
+	return News{}, s.err
+}
+
+func (s stubStore) DeleteNews(context.Context, string) error { return s.err }
+
+func (s stubStore) ListTopics(context.Context, Sort) ([]Topic, error) { return nil, s.err }
+
+func (s stubStore) GetTopic(context.Context, string) (Topic, error) { return Topic{}, s.err }
+
+func (s stubStore) CreateTopic(context.Context, Topic) (Topic, error) {
+	return Topic{}, s.err
+}
+
+func (s stubStore) UpdateTopic(context.Context, string, TopicChange) (Topic, error) {
+	return Topic{}, s.err
+}
+
+func (s stubStore) DeleteTopic(context.Context, string) error { return s.err }
+
+func useStore(t *testing.T, s Store) {
+	saved := store
+	store = s
+	t.Cleanup(func() { store = saved })
+}
+
+// Store errors reach clients as the same statuses and codes the handlers
+// always returned
+func TestHandlersMapStoreErrors(t *testing.T) {
+	article := map[string]interface{}{"title": "T", "content": "C", "topic_id": 1}
+	cases := []struct {
+		method, path string
+		body         interface{}
+		err          error
+		status       int
+		code         string
+	}{
+		{http.MethodGet, "/api/news/1", nil, ErrNotFound, http.StatusNotFound, codeNewsNotFound},
+		{http.MethodGet, "/api/news/1", nil, ErrTimeout, http.StatusServiceUnavailable, codeTimeout},
+		{http.MethodGet, "/api/news", nil, ErrTimeout, http.StatusServiceUnavailable, codeTimeout},
+		{http.MethodPost, "/api/news", article, &ForeignKeyError{Ref: "topic"}, http.StatusBadRequest, codeInvalidReference},
+		{http.MethodPut, "/api/news/1", article, ErrArchived, http.StatusConflict, codeNewsArchived},
+		{http.MethodPatch, "/api/news/1", map[string]int{"topic_id": 9}, &ForeignKeyError{Ref: "topic"}, http.StatusBadRequest, codeInvalidReference},
+		{http.MethodDelete, "/api/news/1", nil, ErrNotFound, http.StatusNotFound, codeNewsNotFound},
+		{http.MethodGet, "/api/topics", nil, ErrConflict, http.StatusConflict, codeConflict},
+		{http.MethodPost, "/api/topics", map[string]string{"name": "Go"}, &DuplicateError{Field: "name"}, http.StatusConflict, codeDuplicate},
+		{http.MethodPut, "/api/topics/1", map[string]string{"name": "Go"}, ErrNotFound, http.StatusNotFound, codeTopicNotFound},
+		{http.MethodDelete, "/api/topics/1", nil, ErrInUse, http.StatusConflict, codeTopicInUse},
+	}
+
+	s := newTestServer(t)
+	for _, tc := range cases {
+		useStore(t, stubStore{err: tc.err})
+		var body ErrorResponse
+		if s.expect(s.do(tc.method, tc.path, tc.body), tc.status, &body) {
+			assert.Equal(t, tc.code, body.Code, "%s %s", tc.method, tc.path)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// timing.go
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// headerInternalClient carries SERVER_TIMING_SECRET on requests from our
+// own services. Only those get a Server-Timing breakdown.
+const headerInternalClient = "X-Internal-Client"
+
+// timingSecret is what X-Internal-Client must match. It is empty, and no
+// response carries Server-Timing, unless SERVER_TIMING_SECRET is set.
+var timingSecret string
+
+func loadTimingSettings() {
+	secret := os.Getenv("SERVER_TIMING_SECRET")
+	if secret != "" && len(secret) < minDebugSecretChars {
+		fatalf("SERVER_TIMING_SECRET must be at least %d characters", minDebugSecretChars)
+	}
+	timingSecret = secret
+}
+
+// internalClient reports whether req carries the timing secret
+func internalClient(req *http.Request) bool {
+	given := req.Header.Get(headerInternalClient)
+	return timingSecret != "" && subtle.ConstantTimeCompare([]byte(given), []byte(timingSecret)) == 1
+}
+
+// routeSamples is how many recent internal requests per route the p95 in
+// Server-Timing is taken over
+const routeSamples = 200
+
+type timingsKey struct{}
+
+// requestTimings accumulates the time one request spends in each phase,
+// e.g. db or render
+type requestTimings struct {
+	mu     sync.Mutex
+	start  time.Time
+	phases map[string]time.Duration
+	order  []string
+}
+
+// observe starts timing a phase of the request behind ctx and returns the
+// function that ends it; calls add up. Without an internal client there
+// is nothing to record and observe does no more than a context lookup.
+func observe(ctx context.Context, phase string) func() {
+	t, _ := ctx.Value(timingsKey{}).(*requestTimings)
+	if t == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if _, ok := t.phases[phase]; !ok {
+			t.order = append(t.order, phase)
+		}
+		t.phases[phase] += elapsed
+	}
+}
+
+// serverTiming adds a Server-Timing header to responses for internal
+// clients: each recorded phase, the request's total, and the p95 total of
+// recent internal requests to the same route. Other requests pass straight
+// through.
+func serverTiming(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !internalClient(c.Request()) {
+			return next(c)
+		}
+
+		t := &requestTimings{start: time.Now(), phases: map[string]time.Duration{}}
+		req := c.Request()
+		c.SetRequest(req.WithContext(context.WithValue(req.Context(), timingsKey{}, t)))
+
+		// Headers are final once the status is written, so the total runs
+		// until then; rendering has finished by that point
+		c.Response().Before(func() {
+			route := c.Request().Method + " " + c.Path()
+			total := time.Since(t.start)
+			p95 := latencies.observe(route, total)
+			c.Response().Header().Set("Server-Timing", t.header(total, p95, route))
+		})
+		return next(c)
+	}
+}
+
+// header formats the timings as Server-Timing metrics, durations in
+// milliseconds
+func (t *requestTimings) header(total, p95 time.Duration, route string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var metrics []string
+	for _, phase := range t.order {
+		metrics = append(metrics, fmt.Sprintf("%s;dur=%s", phase, millis(t.phases[phase])))
+	}
+	metrics = append(metrics,
+		fmt.Sprintf("total;dur=%s", millis(total)),
+		fmt.Sprintf("p95;dur=%s;desc=%q", millis(p95), route))
+	return strings.Join(metrics, ", ")
+}
+
+func millis(d time.Duration) string {
+	return fmt.Sprintf("%.3f", float64(d)/float64(time.Millisecond))
+}
+
+// routeLatencies keeps the most recent totals per route in a ring
+type routeLatencies struct {
+	mu     sync.Mutex
+	routes map[string]*latencyRing
+}
+
+type latencyRing struct {
+	samples []time.Duration
+	next    int
+}
+
+var latencies = &routeLatencies{routes: map[string]*latencyRing{}}
+
+// observe adds a sample for route and returns the route's p95
+func (l *routeLatencies) observe(route string, d time.Duration) time.Duration {
+	l.mu.Lock()
+	r := l.routes[route]
+	if r == nil {
+		r = &latencyRing{}
+		l.routes[route] = r
+	}
+	if len(r.samples) < routeSamples {
+		r.samples = append(r.samples, d)
+	} else {
+		r.samples[r.next] = d
+		r.next = (r.next + 1) % routeSamples
+	}
+	sorted := append([]time.Duration(nil), r.samples...)
+	l.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[(len(sorted)*95+99)/100-1]
+}
+
+// timedStore records the time spent in the Store as the db phase. Handlers
+// that query db directly, such as the archive, collections, search,
+// moderation and admin endpoints, are not broken down: their time shows
+// only in the total.
+type timedStore struct {
+	Store
+}
+
+func (s timedStore) GetNews(ctx context.Context, id string, fields []string, includeTopic bool) (interface{}, error) {
+	defer observe(ctx, "db")()
+	return s.Store.GetNews(ctx, id, fields, includeTopic)
+}
+
+func (s timedStore) ListNews(ctx context.Context, q NewsQuery, fields []string, withTotal bool) ([]interface{}, ListMeta, error) {
+	defer observe(ctx, "db")()
+	return s.Store.ListNews(ctx, q, fields, withTotal)
+}
+
+func (s timedStore) CreateNews(ctx context.Context, req CreateNewsRequest, verdict ModerationResult) (News, error) {
+	defer observe(ctx, "db")()
+	return s.Store.CreateNews(ctx, req, verdict)
+}
+
+func (s timedStore) UpdateNews(ctx context.Context, id string, change NewsChange, verdict ModerationResult) (News, error) {
+	defer observe(ctx, "db")()
+	return s.Store.UpdateNews(ctx, id, change, verdict)
+}
+
+func (s timedStore) DeleteNews(ctx context.Context, id string) error {
+	defer observe(ctx, "db")()
+	return s.Store.DeleteNews(ctx, id)
+}
+
+func (s timedStore) ListTopics(ctx context.Context, order Sort) ([]Topic, error) {
+	defer observe(ctx, "db")()
+	return s.Store.ListTopics(ctx, order)
+}
+
+func (s timedStore) GetTopic(ctx context.Context, id string) (Topic, error) {
+	defer observe(ctx, "db")()
+	return s.Store.GetTopic(ctx, id)
+}
+
+func (s timedStore) CreateTopic(ctx context.Context, topic Topic) (Topic, error) {
+	defer observe(ctx, "db")()
+	return s.Store.CreateTopic(ctx, topic)
+}
+
+func (s timedStore) UpdateTopic(ctx context.Context, id string, change TopicChange) (Topic, error) {
+	defer observe(ctx, "db")()
+	return s.Store.UpdateTopic(ctx, id, change)
+}
+
+func (s timedStore) DeleteTopic(ctx context.Context, id string) error {
+	defer observe(ctx, "db")()
+	return s.Store.DeleteTopic(ctx, id)
+}
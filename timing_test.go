@@ -0,0 +1,106 @@
+// timing_test.go
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testTimingSecret = "timing-secret-for-tests"
+
+func useTimingSecret(t *testing.T, secret string) {
+	saved := timingSecret
+	timingSecret = secret
+	t.Cleanup(func() { timingSecret = saved })
+}
+
+func internalRequest(s *testServer, path, secret string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set(headerInternalClient, secret)
+	rec := httptest.NewRecorder()
+	s.e.ServeHTTP(rec, req)
+	return rec
+}
+
+var serverTimingFormat = regexp.MustCompile(
+	`^db;dur=\d+\.\d{3}, render;dur=\d+\.\d{3}, total;dur=\d+\.\d{3}, p95;dur=\d+\.\d{3};desc="GET /api/topics"$`)
+
+func TestServerTimingForInternalClients(t *testing.T) {
+	useStore(t, timedStore{newMemStore()})
+	useTimingSecret(t, testTimingSecret)
+	s := newTestServer(t)
+
+	rec := internalRequest(s, "/api/topics", testTimingSecret)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Regexp(t, serverTimingFormat, rec.Header().Get("Server-Timing"))
+
+	// Errors are timed too
+	rec = internalRequest(s, "/api/topics/999999", testTimingSecret)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Regexp(t, `^db;dur=\d+\.\d{3}, `, rec.Header().Get("Server-Timing"))
+	assert.Contains(t, rec.Header().Get("Server-Timing"), `desc="GET /api/topics/:id"`)
+}
+
+func TestServerTimingOnlyForInternalClients(t *testing.T) {
+	useStore(t, timedStore{newMemStore()})
+	useTimingSecret(t, testTimingSecret)
+	s := newTestServer(t)
+
+	rec := s.do(http.MethodGet, "/api/topics", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Server-Timing"))
+
+	// The header alone is not enough, it must carry the secret
+	rec = internalRequest(s, "/api/topics", "newsletter")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Server-Timing"))
+
+	// Without SERVER_TIMING_SECRET nobody gets it
+	useTimingSecret(t, "")
+	rec = internalRequest(s, "/api/topics", "")
+	assert.Empty(t, rec.Header().Get("Server-Timing"))
+}
+
+func TestObserveWithoutTimings(t *testing.T) {
+	// Nothing to record into, so this must neither panic nor allocate
+	allocs := testing.AllocsPerRun(100, func() {
+		observe(context.Background(), "db")()
+	})
+	assert.Zero(t, allocs)
+}
+
+func TestRouteLatenciesP95(t *testing.T) {
+	l := &routeLatencies{routes: map[string]*latencyRing{}}
+	var p95 time.Duration
+	for i := 1; i <= 100; i++ {
+		p95 = l.observe("GET /a", time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, 95*time.Millisecond, p95)
+
+	// Only the newest routeSamples count
+	for i := 0; i < routeSamples; i++ {
+		p95 = l.observe("GET /a", time.Millisecond)
+	}
+	assert.Equal(t, time.Millisecond, p95)
+
+	assert.Equal(t, 7*time.Millisecond, l.observe("GET /b", 7*time.Millisecond))
+}
+
+func TestRequestTimingsHeader(t *testing.T) {
+	rt := &requestTimings{phases: map[string]time.Duration{}}
+	ctx := context.WithValue(context.Background(), timingsKey{}, rt)
+	observe(ctx, "db")()
+	observe(ctx, "render")()
+	rt.phases["db"] = 1500 * time.Microsecond
+	rt.phases["render"] = 250 * time.Microsecond
+
+	assert.Equal(t,
+		`db;dur=1.500, render;dur=0.250, total;dur=2.000, p95;dur=3.125;desc="GET /api/news"`,
+		rt.header(2*time.Millisecond, 3125*time.Microsecond, "GET /api/news"))
+}
@@ -0,0 +1,110 @@
+// topicmeta.go
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// topicFieldLimits caps the landing page fields of a topic, in characters.
+// The meta limits are what search engines display; the columns added in
+// migration 0002 enforce the same.
+var topicFieldLimits = map[string]int{
+	"tagline":          160,
+	"long_description": 20000,
+	"meta_title":       70,
+	"meta_description": 160,
+	"header_image_url": 2048,
+}
+
+// topicFieldProblems returns Details for each sent field that is too long,
+// and for a header_image_url that is not an absolute http(s) URL. nil
+// values were not sent; an empty one clears the field.
+func topicFieldProblems(fields map[string]*string) map[string]string {
+	details := map[string]string{}
+	for field, value := range fields {
+		if value == nil {
+			continue
+		}
+		if max := topicFieldLimits[field]; utf8.RuneCountInString(*value) > max {
+			details[field] = "must be at most " + strconv.Itoa(max) + " characters"
+		} else if field == "header_image_url" && *value != "" && !imageURL(*value) {
+			details[field] = "must be an absolute http or https URL"
+		}
+	}
+	return details
+}
+
+// fields maps the limited fields of a create or replace body for
+// topicFieldProblems
+func (t *Topic) fields() map[string]*string {
+	return map[string]*string{
+		"tagline":          &t.Tagline,
+		"long_description": &t.LongDescription,
+		"meta_title":       &t.MetaTitle,
+		"meta_description": &t.MetaDescription,
+		"header_image_url": &t.HeaderImageURL,
+	}
+}
+
+func imageURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// topicFieldsMessage summarizes topicFieldProblems for the error message
+func topicFieldsMessage(details map[string]string) string {
+	fields := make([]string, 0, len(details))
+	for field := range details {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return "Invalid topic fields: " + strings.Join(fields, ", ")
+}
+
+// parseRender reads ?render=, which asks for long_description_html
+// alongside the Markdown source. html is the only format.
+func parseRender(c echo.Context) (html bool, err error) {
+	switch v := c.QueryParam("render"); v {
+	case "":
+		return false, nil
+	case "html":
+		return true, nil
+	default:
+		return false, errors.New("Unknown render " + strconv.Quote(v) + ": must be html")
+	}
+}
+
+var (
+	markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	// Descriptions are written by editors but shown on public pages, so
+	// the HTML keeps only user-generated-content markup: no scripts,
+	// styles, iframes or javascript: links
+	markdownPolicy = bluemonday.UGCPolicy()
+)
+
+// renderMarkdown converts a long description to sanitized HTML
+func renderMarkdown(src string) (string, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(src), &buf); err != nil {
+		return "", err
+	}
+	return string(markdownPolicy.SanitizeBytes(buf.Bytes())), nil
+}
+
+// renderTopic fills long_description_html for ?render=html
+func renderTopic(t *Topic) error {
+	html, err := renderMarkdown(t.LongDescription)
+	t.LongDescriptionHTML = html
+	return err
+}
@@ -0,0 +1,122 @@
+// topicmeta_test.go
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopicFieldProblems(t *testing.T) {
+	long := strings.Repeat("é", 161)
+	ok := "https://cdn.example.com/topics/science.jpg"
+	relative := "/images/science.jpg"
+	script := "javascript:alert(1)"
+	empty := ""
+
+	assert.Empty(t, topicFieldProblems(map[string]*string{"tagline": &empty, "header_image_url": &ok, "meta_title": nil}))
+	assert.Empty(t, topicFieldProblems(map[string]*string{"header_image_url": &empty}))
+	assert.Equal(t, map[string]string{
+		"tagline":          "must be at most 160 characters",
+		"meta_description": "must be at most 160 characters",
+		"header_image_url": "must be an absolute http or https URL",
+	}, topicFieldProblems(map[string]*string{"tagline": &long, "meta_description": &long, "header_image_url": &relative}))
+	assert.Equal(t, map[string]string{"header_image_url": "must be an absolute http or https URL"},
+		topicFieldProblems(map[string]*string{"header_image_url": &script}))
+}
+
+func TestRenderMarkdownSanitizes(t *testing.T) {
+	html, err := renderMarkdown("## Coverage\n\nThe **latest** from [our desk](https://example.com).\n\n<script>alert(1)</script>\n\n[click](javascript:alert(1))")
+	assert.NoError(t, err)
+	assert.Contains(t, html, "<h2>Coverage</h2>")
+	assert.Contains(t, html, "<strong>latest</strong>")
+	assert.Contains(t, html, `href="https://example.com"`)
+	assert.NotContains(t, html, "<script")
+	assert.NotContains(t, html, "javascript:")
+
+	html, err = renderMarkdown("")
+	assert.NoError(t, err)
+	assert.Empty(t, html)
+}
+
+func TestTopicLandingPageFields(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s *testServer) {
+		var topic Topic
+		body := map[string]string{
+			"name":             uniqueName(t, "Landing"),
+			"tagline":          "Research, space and the natural world",
+			"long_description": "Our **science** desk.",
+			"meta_title":       "Science news",
+			"meta_description": "The latest science stories",
+			"header_image_url": "https://cdn.example.com/science.jpg",
+		}
+		if !mustCreate(t, s, "/api/topics", body, &topic, func() string { return "/api/topics/" + strconv.Itoa(topic.ID) }) {
+			return
+		}
+		path := "/api/topics/" + strconv.Itoa(topic.ID)
+		assert.Equal(t, body["tagline"], topic.Tagline)
+		assert.Equal(t, body["tagline"], topic.Description)
+		assert.Equal(t, body["meta_title"], topic.MetaTitle)
+		assert.Equal(t, body["header_image_url"], topic.HeaderImageURL)
+		assert.Empty(t, topic.LongDescriptionHTML)
+
+		var got Topic
+		s.expect(s.do(http.MethodGet, path+"?render=html", nil), http.StatusOK, &got)
+		assert.Equal(t, "<p>Our <strong>science</strong> desk.</p>\n", got.LongDescriptionHTML)
+		assert.Equal(t, body["long_description"], got.LongDescription)
+
+		var listed []Topic
+		s.expect(s.do(http.MethodGet, "/api/topics?render=html", nil), http.StatusOK, &listed)
+		for _, l := range listed {
+			if l.ID == topic.ID {
+				assert.Equal(t, got.LongDescriptionHTML, l.LongDescriptionHTML)
+			}
+		}
+
+		var errBody ErrorResponse
+		s.expect(s.do(http.MethodGet, path+"?render=pdf", nil), http.StatusBadRequest, &errBody)
+		assert.Equal(t, codeInvalidParameter, errBody.Code)
+
+		// Legacy clients write description, which sets the tagline
+		s.expect(s.do(http.MethodPatch, path, map[string]string{"description": "From an old client"}), http.StatusOK, &got)
+		assert.Equal(t, "From an old client", got.Tagline)
+		assert.Equal(t, "From an old client", got.Description)
+		assert.Equal(t, body["meta_title"], got.MetaTitle)
+
+		// An empty value clears a field; an omitted one is kept
+		s.expect(s.do(http.MethodPatch, path, map[string]string{"header_image_url": ""}), http.StatusOK, &got)
+		assert.Empty(t, got.HeaderImageURL)
+		assert.Equal(t, body["meta_description"], got.MetaDescription)
+
+		s.expect(s.do(http.MethodPatch, path, map[string]string{"meta_title": strings.Repeat("x", 71), "header_image_url": "ftp://example.com/a.jpg"}), http.StatusBadRequest, &errBody)
+		assert.Equal(t, codeValidationFailed, errBody.Code)
+		assert.Equal(t, map[string]string{"meta_title": "must be at most 70 characters", "header_image_url": "must be an absolute http or https URL"}, errBody.Details)
+		var tooLong ErrorResponse
+		s.expect(s.do(http.MethodPost, "/api/topics", map[string]string{"name": uniqueName(t, "Too long"), "tagline": strings.Repeat("x", 161)}), http.StatusBadRequest, &tooLong)
+		assert.Equal(t, map[string]string{"tagline": "must be at most 160 characters"}, tooLong.Details)
+
+		// PUT replaces every field
+		s.expect(s.do(http.MethodPut, path, map[string]string{"name": body["name"], "tagline": "Replaced"}), http.StatusOK, &got)
+		assert.Equal(t, "Replaced", got.Tagline)
+		assert.Empty(t, got.LongDescription)
+		assert.Empty(t, got.MetaTitle)
+
+		// Articles embed the tagline only
+		var news News
+		article := map[string]interface{}{"title": "Landing", "content": "Body", "topic_id": topic.ID}
+		if !mustCreate(t, s, "/api/news", article, &news, func() string { return "/api/news/" + strconv.Itoa(news.ID) }) {
+			return
+		}
+		var withTopic struct {
+			Topic map[string]interface{} `json:"topic"`
+		}
+		s.expect(s.do(http.MethodGet, "/api/news/"+strconv.Itoa(news.ID)+"?include=topic", nil), http.StatusOK, &withTopic)
+		assert.Equal(t, "Replaced", withTopic.Topic["tagline"])
+		assert.Equal(t, "Replaced", withTopic.Topic["description"])
+		assert.NotContains(t, withTopic.Topic, "long_description")
+		assert.NotContains(t, withTopic.Topic, "meta_title")
+	})
+}
@@ -0,0 +1,269 @@
+// tracing.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serviceName is the service.name traces report unless OTEL_SERVICE_NAME
+// overrides it
+const serviceName = "news-and-topic-api"
+
+// sqlTracer creates the query spans; nil while tracing is off, in which
+// case initDB opens Postgres without the tracing wrapper
+var sqlTracer trace.Tracer
+
+// tracingEnabled reports whether an OTLP endpoint is configured. Without
+// one the global tracer provider stays a no-op.
+func tracingEnabled() bool {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// setupTracing exports spans over OTLP/HTTP when an endpoint is
+// configured. The exporter, sampler and resource read the standard OTEL_*
+// variables themselves. The returned function flushes pending spans.
+func setupTracing(ctx context.Context) func(context.Context) error {
+	if !tracingEnabled() {
+		return func(context.Context) error { return nil }
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		fatal("Error creating OTLP trace exporter", "error", err)
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		fatal("Error building trace resource", "error", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	sqlTracer = provider.Tracer(serviceName)
+	slog.Info("Tracing enabled", "exporter", "otlp/http")
+	return provider.Shutdown
+}
+
+// traceRequests starts a server span per request, continuing the trace
+// of an incoming traceparent header. Probes are not traced.
+func traceRequests() echo.MiddlewareFunc {
+	return otelecho.Middleware(serviceName, otelecho.WithSkipper(func(c echo.Context) bool {
+		return c.Path() == "/health" || strings.HasPrefix(c.Path(), "/healthz/")
+	}))
+}
+
+// openPostgres opens the Postgres pool, with every query traced when
+// tracing is on
+func openPostgres(dsn string) (*sql.DB, error) {
+	if sqlTracer == nil {
+		return sql.Open("postgres", dsn)
+	}
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(tracedConnector{Connector: connector, tracer: sqlTracer}), nil
+}
+
+// sqlSpanName names a query's span by its operation and first table, e.g.
+// "SELECT news" or "INSERT topics"
+func sqlSpanName(query string) string {
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return "SQL"
+	}
+	op := strings.ToUpper(words[0])
+	after := ""
+	switch op {
+	case "SELECT", "DELETE":
+		after = "FROM"
+	case "INSERT":
+		after = "INTO"
+	case "UPDATE":
+		if len(words) > 1 {
+			return op + " " + sqlTable(words[1])
+		}
+		return op
+	default:
+		return op
+	}
+	for i, w := range words[:len(words)-1] {
+		if strings.EqualFold(w, after) {
+			if table := sqlTable(words[i+1]); table != "" {
+				return op + " " + table
+			}
+			break
+		}
+	}
+	return op
+}
+
+// sqlTable returns w if it names a table rather than opening a subquery
+func sqlTable(w string) string {
+	if strings.HasPrefix(w, "(") {
+		return ""
+	}
+	return strings.TrimRight(w, ",;")
+}
+
+// tracedConnector wraps a driver connector so each statement runs in a
+// child span of the request that issued it. Spans end when the driver
+// returns, so a query's span covers the time to its first row.
+type tracedConnector struct {
+	driver.Connector
+	tracer trace.Tracer
+}
+
+func (c tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{Conn: conn, tracer: c.tracer}, nil
+}
+
+type tracedConn struct {
+	driver.Conn
+	tracer trace.Tracer
+}
+
+func (c *tracedConn) span(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{attribute.String("db.system", "postgresql")}
+	if query != "" {
+		attrs = append(attrs, attribute.String("db.statement", query))
+	}
+	return c.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil && err != driver.ErrSkip {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span := c.span(ctx, sqlSpanName(query), query)
+	rows, err := q.QueryContext(ctx, query, args)
+	endSpan(span, err)
+	return rows, err
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span := c.span(ctx, sqlSpanName(query), query)
+	res, err := e.ExecContext(ctx, query, args)
+	endSpan(span, err)
+	return res, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	ctx, span := c.span(ctx, "PREPARE "+sqlSpanName(query), query)
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	endSpan(span, err)
+	return stmt, err
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	spanCtx, span := c.span(ctx, "BEGIN", "")
+	var tx driver.Tx
+	var err error
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = b.BeginTx(spanCtx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	endSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	return tracedTx{Tx: tx, ctx: ctx, conn: c}, nil
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *tracedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// tracedTx puts COMMIT and ROLLBACK in the trace that began the
+// transaction
+type tracedTx struct {
+	driver.Tx
+	ctx  context.Context
+	conn *tracedConn
+}
+
+func (t tracedTx) Commit() error {
+	_, span := t.conn.span(t.ctx, "COMMIT", "")
+	err := t.Tx.Commit()
+	endSpan(span, err)
+	return err
+}
+
+func (t tracedTx) Rollback() error {
+	_, span := t.conn.span(t.ctx, "ROLLBACK", "")
+	err := t.Tx.Rollback()
+	endSpan(span, err)
+	return err
+}
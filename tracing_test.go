@@ -0,0 +1,131 @@
+// tracing_test.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSQLSpanName(t *testing.T) {
+	cases := map[string]string{
+		"SELECT id, name FROM topics WHERE id = $1":                             "SELECT topics",
+		"\n\t\tSELECT n.id\n\t\tFROM news n JOIN topics t ON t.id = n.topic_id": "SELECT news",
+		"SELECT COUNT(*) FROM (SELECT id FROM news) v":                          "SELECT",
+		"select pg_try_advisory_lock($1)":                                       "SELECT",
+		"INSERT INTO news (title) VALUES ($1)":                                  "INSERT news",
+		"UPDATE topics SET name = $1":                                           "UPDATE topics",
+		"DELETE FROM news_collections WHERE news_id = $1":                       "DELETE news_collections",
+		"WITH moved AS (DELETE FROM news RETURNING *) SELECT 1":                 "WITH",
+		"   ": "SQL",
+	}
+	for query, want := range cases {
+		assert.Equal(t, want, sqlSpanName(query), query)
+	}
+}
+
+func TestTracingEnabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_SDK_DISABLED", "")
+	assert.False(t, tracingEnabled())
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://collector:4318/v1/traces")
+	assert.True(t, tracingEnabled())
+
+	t.Setenv("OTEL_SDK_DISABLED", "true")
+	assert.False(t, tracingEnabled())
+}
+
+// sqliteConnector opens go-sqlite3 connections; the tracing wrapper only
+// needs some driver.Connector to sit on
+type sqliteConnector struct{ dsn string }
+
+func (c sqliteConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.Driver().Open(c.dsn)
+}
+
+func (sqliteConnector) Driver() driver.Driver { return &sqlite3.SQLiteDriver{} }
+
+// Statements, transactions included, become children of the caller's span
+func TestTracedConnector(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	traced := sql.OpenDB(tracedConnector{
+		Connector: sqliteConnector{dsn: filepath.Join(t.TempDir(), "traced.db")},
+		tracer:    tracer,
+	})
+	defer traced.Close()
+
+	ctx, parent := tracer.Start(context.Background(), "request")
+	_, err := traced.ExecContext(ctx, "CREATE TABLE topics (id INTEGER PRIMARY KEY, name TEXT)")
+	assert.NoError(t, err)
+	tx, err := traced.BeginTx(ctx, nil)
+	if assert.NoError(t, err) {
+		_, err = tx.ExecContext(ctx, "INSERT INTO topics (name) VALUES (?)", "Go")
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Commit())
+	}
+	var n int
+	assert.NoError(t, traced.QueryRowContext(ctx, "SELECT COUNT(*) FROM topics").Scan(&n))
+	assert.Equal(t, 1, n)
+	_, err = traced.ExecContext(ctx, "INSERT INTO missing (x) VALUES (1)")
+	assert.Error(t, err)
+	parent.End()
+
+	var names []string
+	for _, span := range recorder.Ended() {
+		if span.Name() == "request" {
+			continue
+		}
+		names = append(names, span.Name())
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID(), span.Name())
+		if span.Name() == "INSERT missing" {
+			assert.Equal(t, "Error", span.Status().Code.String())
+		}
+	}
+	assert.Equal(t, []string{"CREATE", "BEGIN", "INSERT topics", "COMMIT", "SELECT topics", "INSERT missing"}, names)
+}
+
+// Requests continue the caller's trace; probes are left out
+func TestTraceRequests(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	savedProvider, savedPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(savedProvider)
+		otel.SetTextMapPropagator(savedPropagator)
+	})
+
+	e := echo.New()
+	e.Use(traceRequests())
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.GET("/api/topics/:id", ok)
+	e.GET("/healthz/live", ok)
+
+	for _, path := range []string{"/api/topics/1", "/healthz/live"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "GET /api/topics/:id", spans[0].Name())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	}
+}
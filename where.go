@@ -0,0 +1,56 @@
+// where.go
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// where builds a WHERE clause and its arguments together. Conditions are
+// written with ? placeholders, which are numbered $1, $2, ... as they are
+// added, so the list and count queries built from one where always agree
+// and the SQL can't drift from its args. Drivers that bind by position
+// set positional to keep them as plain ?.
+type where struct {
+	conds      []string
+	args       []interface{}
+	positional bool
+}
+
+// add appends cond, binding each ? to the next of args in order. A ?
+// must not be directly followed by a digit. A count mismatch is a
+// programming error and panics.
+func (w *where) add(cond string, args ...interface{}) {
+	if n := strings.Count(cond, "?"); n != len(args) {
+		panic(fmt.Sprintf("where: %q has %d placeholders for %d args", cond, n, len(args)))
+	}
+	var b strings.Builder
+	for _, part := range strings.SplitAfter(cond, "?") {
+		if !strings.HasSuffix(part, "?") {
+			b.WriteString(part)
+			continue
+		}
+		b.WriteString(part[:len(part)-1])
+		b.WriteString(w.arg(args[0]))
+		args = args[1:]
+	}
+	w.conds = append(w.conds, b.String())
+}
+
+// arg binds a value used outside the conditions, such as in FROM or
+// LIMIT, and returns its placeholder
+func (w *where) arg(v interface{}) string {
+	w.args = append(w.args, v)
+	if w.positional {
+		return "?"
+	}
+	return fmt.Sprintf("$%d", len(w.args))
+}
+
+// String is the WHERE clause, or nothing if there are no conditions
+func (w *where) String() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(w.conds, " AND ")
+}
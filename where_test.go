@@ -0,0 +1,118 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	placeholder      = regexp.MustCompile(`\$(\d+)`)
+	placeholderDigit = regexp.MustCompile(`\?\d`)
+)
+
+// assertPlaceholders checks that sql uses exactly $1..$n for n args
+func assertPlaceholders(t *testing.T, sql string, args []interface{}) {
+	seen := map[int]bool{}
+	for _, m := range placeholder.FindAllStringSubmatch(sql, -1) {
+		n, _ := strconv.Atoi(m[1])
+		seen[n] = true
+	}
+	assert.Equal(t, len(args), len(seen), sql)
+	for i := 1; i <= len(args); i++ {
+		assert.True(t, seen[i], "$%d missing from %s", i, sql)
+	}
+}
+
+func TestWhere(t *testing.T) {
+	var w where
+	assert.Equal(t, "", w.String())
+
+	w.add("a = ?", 1)
+	w.add("b BETWEEN ? AND ?", 2, 3)
+	w.add("c IS NULL")
+	assert.Equal(t, "WHERE a = $1 AND b BETWEEN $2 AND $3 AND c IS NULL", w.String())
+	assert.Equal(t, "$4", w.arg(10))
+	assert.Equal(t, []interface{}{1, 2, 3, 10}, w.args)
+
+	assert.Panics(t, func() { w.add("d = ?") })
+	assert.Panics(t, func() { w.add("d = ?", 1, 2) })
+}
+
+func TestWherePositional(t *testing.T) {
+	w := where{positional: true}
+	w.add("a = ?", 1)
+	w.add("b BETWEEN ? AND ?", 2, 3)
+	assert.Equal(t, "WHERE a = ? AND b BETWEEN ? AND ?", w.String())
+	assert.Equal(t, "?", w.arg(10))
+	assert.Equal(t, []interface{}{1, 2, 3, 10}, w.args)
+}
+
+// TestNewsQueryFilterCombinations runs every combination of the listing
+// filters, with and without a caller condition and arg bound first
+func TestNewsQueryFilterCombinations(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	setters := []func(*NewsQuery){
+		func(q *NewsQuery) { q.Dates.From = day },
+		func(q *NewsQuery) { q.Dates.Until = day },
+		func(q *NewsQuery) { q.Search = "50%" },
+		func(q *NewsQuery) { q.TopicIDs = []int{1, 2} },
+	}
+
+	for mask := 0; mask < 1<<len(setters); mask++ {
+		for _, prefixed := range []bool{false, true} {
+			var q NewsQuery
+			want := 0
+			for i, set := range setters {
+				if mask&(1<<i) != 0 {
+					set(&q)
+					want++
+				}
+			}
+
+			var w where
+			from := ""
+			if prefixed {
+				from = "plainto_tsquery(" + w.arg("text") + ")"
+				w.add("n.topic_id = ?", 7)
+				want++
+			}
+			q.filter(&w)
+			sql := from + " " + w.String() + " LIMIT " + w.arg(20) + " OFFSET " + w.arg(0)
+
+			assert.Equal(t, want, len(w.conds), "mask %b", mask)
+			assertPlaceholders(t, sql, w.args)
+		}
+	}
+}
+
+func FuzzWherePlaceholders(f *testing.F) {
+	f.Add("a = ?", "b IN (?, ?)", uint8(3))
+	f.Add("", "?", uint8(0))
+	f.Add("x = 1", "(y = ? OR z = ?)", uint8(1))
+
+	f.Fuzz(func(t *testing.T, first, second string, extra uint8) {
+		// Literal $n in the input would be indistinguishable from ours,
+		// and ?0 would render as $10
+		if strings.Contains(first+second, "$") || placeholderDigit.MatchString(first+second) {
+			t.Skip()
+		}
+
+		var w where
+		for _, cond := range []string{first, second} {
+			args := make([]interface{}, strings.Count(cond, "?"))
+			w.add(cond, args...)
+		}
+		sql := w.String()
+		for i := 0; i < int(extra%4); i++ {
+			sql += " " + w.arg(i)
+		}
+
+		assert.NotContains(t, sql, "?")
+		assertPlaceholders(t, sql, w.args)
+	})
+}